@@ -1,7 +1,12 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/kirides/vdfsbuilder"
@@ -10,49 +15,134 @@ import (
 	"github.com/sethvargo/go-githubactions"
 )
 
+type options struct {
+	outFile   string
+	baseDir   string
+	timestamp string
+	location  *time.Location
+	format    vdf.Format
+	tsPolicy  vdf.TimestampPolicy
+	verify    bool
+}
+
 func main() {
-	inFile := strings.TrimSpace(githubactions.GetInput("in"))
+	inFiles := strings.TrimSpace(githubactions.GetInput("in"))
 	outFile := strings.TrimSpace(githubactions.GetInput("out"))
 	baseDir := strings.TrimSpace(githubactions.GetInput("baseDir"))
 	tsOverrideStr := strings.TrimSpace(githubactions.GetInput("ts"))
+	utc := strings.TrimSpace(githubactions.GetInput("utc"))
+	format := strings.TrimSpace(githubactions.GetInput("format"))
+	tsPolicy := strings.TrimSpace(githubactions.GetInput("tsPolicy"))
+	verify := strings.EqualFold(strings.TrimSpace(githubactions.GetInput("verify")), "true")
+
+	// one script or pattern per line
+	var patterns []string
+	for _, line := range strings.Split(inFiles, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			patterns = append(patterns, line)
+		}
+	}
+	scripts, err := vdfsbuilder.ExpandScripts(patterns)
+	if err != nil {
+		githubactions.Fatalf("failed to find input files. %v", err)
+	}
+	if len(scripts) == 0 {
+		githubactions.Fatalf("no input file given")
+	}
+	if outFile != "" && len(scripts) > 1 {
+		githubactions.Fatalf("out can only be used with a single input file")
+	}
+
+	opts := options{outFile: outFile, baseDir: baseDir, timestamp: tsOverrideStr, location: time.UTC, verify: verify}
+	if utc != "" && !strings.EqualFold(utc, "true") {
+		opts.location = time.Local
+	}
+	// "git" is resolved per input file, it depends on BaseDir
+	if tsOverrideStr != "" && !strings.EqualFold(tsOverrideStr, "git") {
+		if _, err := vdf.ParseTimestamp(tsOverrideStr, opts.location); err != nil {
+			githubactions.Fatalf("failed to parse ts %q. %v", tsOverrideStr, err)
+		}
+	}
+	if format != "" {
+		if opts.format, err = vdf.ParseFormat(format); err != nil {
+			githubactions.Fatalf("failed to parse format %q. %v", format, err)
+		}
+	}
+	if tsPolicy != "" {
+		if opts.tsPolicy, err = vdf.ParseTimestampPolicy(tsPolicy); err != nil {
+			githubactions.Fatalf("failed to parse tsPolicy %q. %v", tsPolicy, err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
+	var failed []string
+	for _, script := range scripts {
+		githubactions.Group(script)
+		err := build(ctx, script, opts)
+		githubactions.EndGroup()
+		if err != nil {
+			githubactions.Errorf("%v", err)
+			failed = append(failed, script)
+		}
+		if ctx.Err() != nil {
+			githubactions.Fatalf("interrupted")
+		}
+	}
+	if len(failed) != 0 {
+		githubactions.Fatalf("%d of %d input files failed: %s", len(failed), len(scripts), strings.Join(failed, ", "))
+	}
+	githubactions.Infof("Built %d input file(s)", len(scripts))
+}
+
+func build(ctx context.Context, inFile string, opts options) error {
 	vm, err := vdf.ParseVM(inFile)
 	if err != nil {
-		githubactions.Fatalf("failed to parse input file %q. %v", inFile, err)
+		return fmt.Errorf("failed to parse input file %q. %w", inFile, err)
 	}
 	// allow for custom base directory
-	if baseDir != "" {
-		vm.BaseDir = baseDir
-		githubactions.Infof("Overwriting vm.BaseDir (baseDir): %q", baseDir)
+	if opts.baseDir != "" {
+		vm.BaseDir = opts.baseDir
+		githubactions.Infof("Overwriting vm.BaseDir (baseDir): %q", opts.baseDir)
 	}
 
 	vm.VDFName = strings.TrimPrefix(vm.VDFName, `.\`)
 
 	vdfsbuilder.SanitizeVM(vm)
 
-	if outFile != "" {
-		vm.VDFName = outFile
-		githubactions.Infof("Overwriting vm.VDFName (out): %q", outFile)
+	if opts.outFile != "" {
+		vm.VDFName = opts.outFile
+		githubactions.Infof("Overwriting vm.VDFName (out): %q", opts.outFile)
 	}
 
-	// default to current time before override
-	vm.Timestamp = time.Now()
+	if opts.format != 0 {
+		vm.Format = opts.format
+		githubactions.Infof("Overwriting vm.Format (format): %s", vm.Format)
+	}
 
-	if tsOverrideStr != "" {
-		location := time.Local
-		if true /* *tsIsUtc */ {
-			location = time.UTC
-		}
-		parsed, err := time.ParseInLocation("2006-01-02 15:04:05", tsOverrideStr, location)
-		if err != nil {
-			githubactions.Warningf("Failed to parse %q flag. %v", tsOverrideStr, err)
-		} else {
-			githubactions.Infof("Override: Timestamp set to %q (%s)\n", parsed.Format("2006-01-02 15:04:05"), location)
-			vm.Timestamp = parsed
-		}
+	source, err := vdfsbuilder.ResolveTimestamp(vm, opts.timestamp, opts.location)
+	if err != nil {
+		return fmt.Errorf("failed to resolve the timestamp. %w", err)
+	}
+	githubactions.Infof("Timestamp set to %q (%s) from %s", vm.Timestamp.Format(vdf.TimestampLayout), opts.location, source)
+
+	vm.TimestampPolicy = opts.tsPolicy
+	if stored, err := vm.Format.StoredTime(vm.Timestamp, vm.TimestampPolicy); err != nil {
+		return err
+	} else if source != "current time" && !stored.Equal(vm.Timestamp) {
+		githubactions.Noticef("Timestamp %s is stored as %s", vm.Timestamp.Format(vdf.TimestampLayout), stored.Format(vdf.TimestampLayout))
+	}
+
+	if err := vm.ExecuteContext(ctx); err != nil {
+		return fmt.Errorf("failed to execute %q. %w", inFile, err)
 	}
 
-	if err := vm.Execute(); err != nil {
-		githubactions.Fatalf("failed to execute %q. %v", inFile, err)
+	if opts.verify {
+		if err := vm.VerifyOutput(); err != nil {
+			return fmt.Errorf("verification of %q failed. %w", vm.VDFName, err)
+		}
+		githubactions.Infof("Verified %q", vm.VDFName)
 	}
+	return nil
 }
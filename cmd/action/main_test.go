@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kirides/vdfsbuilder/vdf"
+)
+
+func TestBuild(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "_work"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "_work", "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(dir, "test.vm")
+	content := "[BEGINVDF]\r\nBaseDir=.\\\r\nVDFName=.\\Test.vdf\r\n[FILES]\r\n_work\\* -r\r\n[ENDVDF]\r\n"
+	if err := os.WriteFile(script, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "Out.vdf")
+	opts := options{outFile: out, baseDir: dir, timestamp: "2021-11-28 12:31:40", location: time.UTC, format: vdf.FormatV3, verify: true}
+
+	if err := build(context.Background(), script, opts); err != nil {
+		t.Fatal(err)
+	}
+	r, err := vdf.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if r.Header.Format() != vdf.FormatV3 {
+		t.Errorf("expected format %s, got %s", vdf.FormatV3, r.Header.Format())
+	}
+	if _, err := r.Stat("_WORK/A.TXT"); err != nil {
+		t.Errorf("expected the archive to contain _WORK/A.TXT. %v", err)
+	}
+	if want := time.Date(2021, 11, 28, 12, 31, 40, 0, time.UTC); !r.Header.Time().Equal(want) {
+		t.Errorf("expected timestamp %v, got %v", want, r.Header.Time())
+	}
+
+	opts.timestamp = "tomorrow"
+	if err := build(context.Background(), script, opts); err == nil || !strings.Contains(err.Error(), "failed to resolve the timestamp") {
+		t.Errorf("expected a timestamp error, got %v", err)
+	}
+	if err := build(context.Background(), filepath.Join(dir, "missing.vm"), opts); err == nil {
+		t.Errorf("expected an error for a missing script")
+	}
+}
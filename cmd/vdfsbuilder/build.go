@@ -0,0 +1,446 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kirides/vdfsbuilder"
+	"github.com/kirides/vdfsbuilder/vdf"
+)
+
+type buildOptions struct {
+	outFile       string
+	baseDir       string
+	format        vdf.Format
+	maxVolumeSize int64
+	keepBackup    bool
+	verify        bool
+	timestamp     string
+	location      *time.Location
+	tsPolicy      vdf.TimestampPolicy
+	workers       int
+	noCache       bool
+	ifChanged     bool
+	depfile       string
+	dryRun        bool
+	explain       bool
+}
+
+// buildJob is the build of a single script. While several scripts are
+// built at the same time, its output is collected and printed once it is done.
+type buildJob struct {
+	script          string
+	vm              *vdf.VM
+	timestampSource string
+	out             io.Writer
+	err             error
+	skipped         string // why nothing was written
+	duration        time.Duration
+}
+
+func runBuild(args []string) int {
+	fset := flag.NewFlagSet("build", flag.ContinueOnError)
+	outFile := fset.String("o", "", "override output filepath, only for a single script")
+	depfile := fset.String("MF", "", "write the archive and its inputs as a depfile for make and ninja, only for a single script")
+	baseDir := fset.String("b", "", "base directory (substitution for \".\\\")")
+	format := fset.String("vdf-format", "", "override the archive format, \"2\" (default) or \"3\" for 64 bit sizes")
+	split := fset.String("split", "", "split the output into volumes of at most this size, e.g. \"4G\" or \"500MB\"")
+	keepBackup := fset.Bool("bak", false, "keep the previous archive as \"<output>.bak\"")
+	verify := fset.Bool("verify", false, "re-open the written archive and compare every entry against its source file")
+	tsOverrideStr := fset.String("ts", "", "a Timestamp like \"2021-11-28 12:31:40\", \"2021-11-28\", RFC 3339 or unix epoch seconds, or \"git\" for the time of the HEAD commit.\nDefaults to the Timestamp= of the script, $SOURCE_DATE_EPOCH or the current time")
+	tsPolicy := fset.String("ts-policy", "clamp", "what to do with timestamps the format can not store (before 1980 or after 2107 for V2), \"clamp\" or \"error\"")
+	tsIsUtc := fset.Bool("utc", true, "if the \"ts\" argument and the Timestamp= of the script should be interpreted as UTC time, otherwise as local time")
+	jobs := fset.Int("j", runtime.NumCPU(), "number of scripts to build at the same time")
+	workers := fset.Int("workers", 0, "number of files of a script read and hashed at the same time (default number of CPUs divided by the scripts built at the same time)")
+	noCache := fset.Bool("no-cache", false, "hash every file instead of reusing the hashes of files unchanged since the last build")
+	dryRun := fset.Bool("dry-run", false, "print the archives that would be written, with header, tree and deduplication, without writing anything")
+	explain := fset.Bool("explain", false, "print which masks included or excluded each file found")
+	ifChanged := fset.Bool("if-changed", false, "skip the build if files, script and options are unchanged since the last build with -if-changed")
+	setUsage(fset, "build [options] script.vm...",
+		"builds the archives described by one or more scripts, patterns like \"*.vm\" are expanded.",
+		"\"build\" may be omitted, "+invocation()+" [options] script.vm works as well.")
+	if code, ok := parseFlags(fset, args); !ok {
+		return code
+	}
+	if fset.NArg() < 1 || *jobs < 1 || *workers < 0 {
+		fset.Usage()
+		return exitUsage
+	}
+	scripts, err := vdfsbuilder.ExpandScripts(fset.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitUsage
+	}
+	if *outFile != "" && len(scripts) > 1 {
+		fmt.Fprintln(os.Stderr, "-o can only be used with a single script")
+		return exitUsage
+	}
+	if *depfile != "" && len(scripts) > 1 {
+		fmt.Fprintln(os.Stderr, "-MF can only be used with a single script")
+		return exitUsage
+	}
+
+	opts := buildOptions{
+		outFile:    *outFile,
+		baseDir:    *baseDir,
+		keepBackup: *keepBackup,
+		verify:     *verify,
+		timestamp:  *tsOverrideStr,
+		location:   time.UTC,
+		workers:    *workers,
+		noCache:    *noCache,
+		ifChanged:  *ifChanged,
+		depfile:    *depfile,
+		dryRun:     *dryRun,
+		explain:    *explain,
+	}
+	if opts.workers == 0 {
+		// the scripts built at the same time share the CPUs
+		opts.workers = max(1, runtime.NumCPU()/min(*jobs, len(scripts)))
+	}
+	if !*tsIsUtc {
+		opts.location = time.Local
+	}
+	// "git" is resolved per script, it depends on BaseDir
+	if opts.timestamp != "" && !strings.EqualFold(opts.timestamp, "git") {
+		if _, err := vdf.ParseTimestamp(opts.timestamp, opts.location); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to parse %q flag. %v\n", *tsOverrideStr, err)
+			return exitUsage
+		}
+	}
+	if opts.tsPolicy, err = vdf.ParseTimestampPolicy(*tsPolicy); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse %q flag. %v\n", *tsPolicy, err)
+		return exitUsage
+	}
+	if *format != "" {
+		if opts.format, err = vdf.ParseFormat(*format); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to parse %q flag. %v\n", *format, err)
+			return exitUsage
+		}
+	}
+	if *split != "" {
+		if opts.maxVolumeSize, err = vdf.ParseSize(*split); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to parse %q flag. %v\n", *split, err)
+			return exitUsage
+		}
+	}
+
+	wd, _ := os.Getwd()
+	fmt.Fprintf(os.Stdout, "working directory: %q\n", wd)
+
+	buildJobs := make([]*buildJob, len(scripts))
+	for i, script := range scripts {
+		job := &buildJob{script: script, out: os.Stdout}
+		if len(scripts) > 1 {
+			job.out = &bytes.Buffer{}
+		}
+		job.vm, job.timestampSource, job.err = prepareBuild(script, opts, job.out)
+		buildJobs[i] = job
+	}
+	checkOutputConflicts(buildJobs)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var wg sync.WaitGroup
+	var printMu sync.Mutex
+	limit := make(chan struct{}, *jobs)
+	for _, job := range buildJobs {
+		if job.err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			start := time.Now()
+			job.err = executeBuild(ctx, job, opts)
+			job.duration = time.Since(start)
+			if buf, ok := job.out.(*bytes.Buffer); ok {
+				printMu.Lock()
+				defer printMu.Unlock()
+				fmt.Fprintf(os.Stdout, "== %s\n", job.script)
+				io.Copy(os.Stdout, buf)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		stop()
+		for _, job := range buildJobs {
+			if errors.Is(job.err, ctx.Err()) {
+				fmt.Fprintf(os.Stderr, "build of %q interrupted, partial output was removed\n", job.script)
+			}
+		}
+		return exitInterrupted
+	}
+	if len(buildJobs) > 1 {
+		printSummary(buildJobs)
+	}
+
+	result := exitOK
+	for _, job := range buildJobs {
+		if job.err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", job.err)
+			result = exitFailure
+		}
+	}
+	return result
+}
+
+// prepareBuild parses script and applies the options to it.
+// It returns where the timestamp of the archive came from.
+func prepareBuild(script string, opts buildOptions, out io.Writer) (*vdf.VM, string, error) {
+	vm, err := vdf.ParseVM(script)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse input %q. %w", script, err)
+	}
+	// allow for custom base directory
+	if opts.baseDir != "" {
+		vm.BaseDir = opts.baseDir
+	}
+
+	vm.VDFName = strings.TrimPrefix(vm.VDFName, `.\`)
+
+	vdfsbuilder.SanitizeVM(vm)
+
+	if opts.outFile != "" {
+		vm.VDFName = opts.outFile
+	}
+
+	source, err := vdfsbuilder.ResolveTimestamp(vm, opts.timestamp, opts.location)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to set the timestamp of %q. %w", script, err)
+	}
+	if source != "current time" {
+		fmt.Fprintf(out, "Timestamp set to %q (%s) from %s\n", vm.Timestamp.Format(vdf.TimestampLayout), opts.location, source)
+	}
+	vm.TimestampPolicy = opts.tsPolicy
+	if opts.format != 0 {
+		vm.Format = opts.format
+	}
+	if stored, err := vm.Format.StoredTime(vm.Timestamp, vm.TimestampPolicy); err != nil {
+		return nil, "", fmt.Errorf("failed to set the timestamp of %q. %w", script, err)
+	} else if source != "current time" && !stored.Equal(vm.Timestamp) {
+		fmt.Fprintf(out, "notice: timestamp %s is stored as %s\n", vm.Timestamp.Format(vdf.TimestampLayout), stored.Format(vdf.TimestampLayout))
+	}
+	vm.KeepBackup = opts.keepBackup
+	if opts.explain {
+		vm.OnMaskDecision = func(d vdf.MaskDecision) { printDecision(out, d, false) }
+	}
+	vm.Workers = opts.workers
+	if opts.maxVolumeSize != 0 {
+		vm.MaxVolumeSize = opts.maxVolumeSize
+	}
+	if !opts.noCache {
+		if path, err := vdf.DefaultHashCachePath(vm.VDFName); err != nil {
+			fmt.Fprintf(out, "warning: hashing every file, no cache available. %v\n", err)
+		} else {
+			vm.HashCache = vdf.LoadHashCache(path)
+		}
+	}
+	return vm, source, nil
+}
+
+func executeBuild(ctx context.Context, job *buildJob, opts buildOptions) error {
+	script, vm, out := job.script, job.vm, job.out
+	if opts.dryRun {
+		planned, err := vm.Plan(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("failed to plan %q. %w", script, err)
+		}
+		printPlan(out, planned)
+		job.skipped = "dry run"
+		return nil
+	}
+
+	var fingerprint string
+	if opts.ifChanged {
+		var err error
+		if fingerprint, err = inputFingerprint(ctx, vm, job.timestampSource); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("failed to fingerprint the inputs of %q. %w", script, err)
+		}
+		if vdfsbuilder.UpToDate(vm, fingerprint) {
+			fmt.Fprintf(out, "%q is up to date\n", vm.VDFName)
+			job.skipped = "up to date"
+			// the fingerprint lists the same inputs the build would have
+			return writeDepfile(vm, script, opts.depfile)
+		}
+	}
+
+	if err := vm.ExecuteContext(ctx); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("failed to execute %q. %w", script, err)
+	}
+
+	printManifest(out, vm.VDFName, vm.Volumes())
+	if vm.HashCache != nil {
+		if err := vm.HashCache.Save(); err != nil {
+			fmt.Fprintf(out, "warning: failed to save hash cache %q. %v\n", vm.HashCache.Path(), err)
+		}
+	}
+
+	if opts.verify {
+		if err := vm.VerifyOutput(); err != nil {
+			return fmt.Errorf("verification of %q failed. %w", vm.VDFName, err)
+		}
+		for _, v := range vm.Volumes() {
+			fmt.Fprintf(out, "verified %q\n", v.Name)
+		}
+	}
+	if err := writeDepfile(vm, script, opts.depfile); err != nil {
+		return err
+	}
+	if opts.ifChanged {
+		if err := vdfsbuilder.WriteFingerprint(vm, fingerprint); err != nil {
+			fmt.Fprintf(out, "warning: failed to store the fingerprint, the next build can not be skipped. %v\n", err)
+		}
+	}
+	return nil
+}
+
+// writeDepfile writes the depfile of the archive built from script to path, if one was requested.
+func writeDepfile(vm *vdf.VM, script, path string) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(vdfsbuilder.Depfile(vm, script)), 0644); err != nil {
+		return fmt.Errorf("failed to write depfile %q. %w", path, err)
+	}
+	return nil
+}
+
+// inputFingerprint fingerprints the inputs of vm. The time of the build itself
+// is no input, archives stamped with the current time are kept until something else changes.
+func inputFingerprint(ctx context.Context, vm *vdf.VM, timestampSource string) (string, error) {
+	// the files are only explained once, by the search of the fingerprint
+	defer func() { vm.OnMaskDecision = nil }()
+	if timestampSource == "current time" {
+		ts := vm.Timestamp
+		defer func() { vm.Timestamp = ts }()
+		vm.Timestamp = time.Time{}
+	}
+	return vm.Fingerprint(ctx)
+}
+
+// checkOutputConflicts fails jobs which would write the archive of an earlier job.
+func checkOutputConflicts(jobs []*buildJob) {
+	owners := make(map[string]string)
+	for _, job := range jobs {
+		if job.err != nil {
+			continue
+		}
+		key, err := filepath.Abs(job.vm.VDFName)
+		if err != nil {
+			key = job.vm.VDFName
+		}
+		// file systems on Windows and macOS ignore case
+		key = strings.ToLower(key)
+		if owner, ok := owners[key]; ok {
+			job.err = fmt.Errorf("%q writes %q, which is already written by %q", job.script, job.vm.VDFName, owner)
+			continue
+		}
+		owners[key] = job.script
+	}
+}
+
+func printSummary(jobs []*buildJob) {
+	failed, skipped := 0, 0
+	fmt.Fprintln(os.Stdout)
+	for _, job := range jobs {
+		if job.err != nil {
+			failed++
+			fmt.Fprintf(os.Stdout, "FAILED %s\n", job.script)
+			continue
+		}
+		if job.skipped != "" {
+			skipped++
+			fmt.Fprintf(os.Stdout, "OK     %s -> %s (%s)\n", job.script, job.vm.VDFName, job.skipped)
+			continue
+		}
+		var files int
+		var size int64
+		for _, v := range job.vm.Volumes() {
+			files += v.Files
+			size += v.Size
+		}
+		fmt.Fprintf(os.Stdout, "OK     %s -> %s (%d files, %s, %s)\n",
+			job.script, job.vm.VDFName, files, vdf.FormatSize(size), job.duration.Round(time.Millisecond))
+	}
+	fmt.Fprintf(os.Stdout, "built %d of %d scripts", len(jobs)-failed-skipped, len(jobs))
+	if skipped != 0 {
+		fmt.Fprintf(os.Stdout, ", %d skipped", skipped)
+	}
+	fmt.Fprintln(os.Stdout)
+}
+
+// printPlan prints the archives a build would write.
+func printPlan(w io.Writer, planned []vdf.PlannedVolume) {
+	for i, v := range planned {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		r := v.Archive
+		var content int64
+		for _, f := range r.Files {
+			if !f.IsDir() {
+				content += int64(f.Size)
+			}
+		}
+		fmt.Fprintf(w, "Archive:   %s (%s, not written)\n", v.Name, vdf.FormatSize(r.Size()))
+		fmt.Fprintf(w, "Format:    %s\n", r.Header.Format())
+		printHeader(w, r.Header)
+		fmt.Fprintf(w, "Content:   %s in files", vdf.FormatSize(content))
+		if saved := content - r.StoredSize(); saved > 0 {
+			fmt.Fprintf(w, ", %s saved by deduplication", vdf.FormatSize(saved))
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w)
+		printTree(w, r, false)
+	}
+}
+
+// printManifest lists the volumes of a split build and warns about volumes
+// left over from an earlier build, whether the build was split or not.
+func printManifest(w io.Writer, name string, volumes []vdf.Volume) {
+	if len(volumes) > 1 {
+		for i, v := range volumes {
+			fmt.Fprintf(w, "volume %d: %s (%d files, %s)\n", i+1, v.Name, v.Files, vdf.FormatSize(v.Size))
+			for _, p := range v.Subtrees {
+				fmt.Fprintf(w, "  %s\n", p)
+			}
+		}
+	}
+	// the game would load a left over volume of a previous build as well
+	if stale := vdf.VolumeName(name, len(volumes)+1); fileExists(stale) {
+		fmt.Fprintf(w, "warning: %q is left over from a previous build\n", stale)
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kirides/vdfsbuilder/vdf"
+)
+
+func TestPrintManifestWarnsAboutLeftOverVolumes(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "Mod.vdf")
+	// left over from an earlier split build
+	if err := os.WriteFile(vdf.VolumeName(name, 2), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	printManifest(&b, name, []vdf.Volume{{Name: name, Files: 1}})
+	if !strings.Contains(b.String(), "Mod_2.vdf\" is left over") {
+		t.Errorf("expected a warning for an unsplit build, got %q", b.String())
+	}
+}
+
+func TestBuildWritesDepfileWhenUpToDate(t *testing.T) {
+	useTempCacheDir(t)
+	dir, script := writeTestScript(t)
+	depfile := filepath.Join(t.TempDir(), "test.d")
+	args := []string{"build", "-if-changed", "-MF", depfile, script}
+
+	if code := run(args); code != exitOK {
+		t.Fatalf("expected exit code %d, got %d", exitOK, code)
+	}
+	want, err := os.ReadFile(depfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(depfile); err != nil {
+		t.Fatal(err)
+	}
+
+	// skipped as up to date, the depfile is written all the same
+	if code := run(args); code != exitOK {
+		t.Fatalf("expected exit code %d, got %d", exitOK, code)
+	}
+	got, err := os.ReadFile(depfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("expected depfile %q, got %q", want, got)
+	}
+	if !strings.Contains(string(got), "a.txt") {
+		t.Errorf("expected the packed file in the depfile, got %q", got)
+	}
+
+	// nothing next to the archive a "* -r" mask could pack
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != "_work" && e.Name() != "test.vm" && e.Name() != "Test.vdf" {
+			t.Errorf("unexpected file %q next to the archive", e.Name())
+		}
+	}
+}
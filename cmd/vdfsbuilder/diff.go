@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kirides/vdfsbuilder/vdf"
+)
+
+func runDiff(args []string) int {
+	fset := flag.NewFlagSet("diff", flag.ContinueOnError)
+	ignoreHeader := fset.Bool("ignore-header", false, "only compare entries, not comment, version and timestamp")
+	setUsage(fset, "diff [options] old.vdf new.vdf",
+		"prints entries only in old.vdf (-), only in new.vdf (+) and with different content (M).",
+		"exits with 1 if the archives differ.")
+	if code, ok := parseFlags(fset, args); !ok {
+		return code
+	}
+	if fset.NArg() != 2 {
+		fset.Usage()
+		return exitUsage
+	}
+
+	var readers [2]*vdf.Reader
+	for i, name := range fset.Args() {
+		r, err := vdf.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open %q. %v\n", name, err)
+			return exitFailure
+		}
+		defer r.Close()
+		readers[i] = r
+	}
+
+	differences := 0
+	if !*ignoreHeader {
+		differences += diffHeaders(readers[0].Header, readers[1].Header)
+	}
+	n, err := diffEntries(readers[0], readers[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to compare archives. %v\n", err)
+		return exitFailure
+	}
+	if differences += n; differences != 0 {
+		return exitFailure
+	}
+	return exitOK
+}
+
+func diffHeaders(a, b vdf.Header) int {
+	differences := 0
+	compare := func(field, x, y string) {
+		if x != y {
+			fmt.Printf("header %s: %q -> %q\n", field, x, y)
+			differences++
+		}
+	}
+	compare("comment", a.Comment.String(), b.Comment.String())
+	compare("version", a.Version.String(), b.Version.String())
+	compare("timestamp", a.Time().Format(time.DateTime), b.Time().Format(time.DateTime))
+	return differences
+}
+
+// diffEntries compares the files of a and b by path, ignoring case.
+func diffEntries(a, b *vdf.Reader) (int, error) {
+	files := func(r *vdf.Reader) map[string]*vdf.File {
+		m := make(map[string]*vdf.File)
+		for _, f := range r.Files {
+			if !f.IsDir() {
+				m[strings.ToUpper(f.Path)] = f
+			}
+		}
+		return m
+	}
+	oldFiles, newFiles := files(a), files(b)
+
+	paths := make([]string, 0, len(oldFiles)+len(newFiles))
+	for p := range oldFiles {
+		paths = append(paths, p)
+	}
+	for p := range newFiles {
+		if _, ok := oldFiles[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	slices.Sort(paths)
+
+	differences := 0
+	for _, p := range paths {
+		x, inOld := oldFiles[p]
+		y, inNew := newFiles[p]
+		switch {
+		case !inNew:
+			fmt.Printf("- %s\n", x.Path)
+		case !inOld:
+			fmt.Printf("+ %s\n", y.Path)
+		case x.Size != y.Size:
+			fmt.Printf("M %s (%d -> %d bytes)\n", y.Path, x.Size, y.Size)
+		default:
+			same, err := sameContent(x, y)
+			if err != nil {
+				return differences, fmt.Errorf("failed to compare %q. %w", y.Path, err)
+			}
+			if same {
+				continue
+			}
+			fmt.Printf("M %s\n", y.Path)
+		}
+		differences++
+	}
+	return differences, nil
+}
+
+func sameContent(a, b *vdf.File) (bool, error) {
+	hash := func(f *vdf.File) ([]byte, error) {
+		h := sha256.New()
+		if _, err := io.Copy(h, f.Open()); err != nil {
+			return nil, err
+		}
+		return h.Sum(nil), nil
+	}
+	x, err := hash(a)
+	if err != nil {
+		return false, err
+	}
+	y, err := hash(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(x, y), nil
+}
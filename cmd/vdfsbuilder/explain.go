@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kirides/vdfsbuilder"
+	"github.com/kirides/vdfsbuilder/vdf"
+)
+
+func runExplain(args []string) int {
+	fset := flag.NewFlagSet("explain", flag.ContinueOnError)
+	baseDir := fset.String("b", "", "base directory (substitution for \".\\\")")
+	setUsage(fset, "explain [options] script.vm path...",
+		"reports whether the script packs each path and which [FILES], [EXCLUDE] and [INCLUDE] masks decided it.",
+		"paths are relative to the BaseDir of the script, absolute paths below it work as well.")
+	if code, ok := parseFlags(fset, args); !ok {
+		return code
+	}
+	if fset.NArg() < 2 {
+		fset.Usage()
+		return exitUsage
+	}
+
+	script := fset.Arg(0)
+	vm, err := vdf.ParseVM(script)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to parse input %q. %v\n", script, err)
+		return exitFailure
+	}
+	if *baseDir != "" {
+		vm.BaseDir = *baseDir
+	}
+	vdfsbuilder.SanitizeVM(vm)
+
+	paths := make([]string, 0, fset.NArg()-1)
+	for _, p := range fset.Args()[1:] {
+		rel, err := relativeToBase(vm.BaseDir, p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return exitUsage
+		}
+		paths = append(paths, rel)
+	}
+	for i, d := range vm.Explain(paths...) {
+		if i > 0 {
+			fmt.Println()
+		}
+		printDecision(os.Stdout, d, true)
+	}
+	return exitOK
+}
+
+// relativeToBase returns absolute paths relative to base, other paths are kept.
+func relativeToBase(base, path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return path, nil
+	}
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absBase, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%q is not below the BaseDir %q", path, absBase)
+	}
+	return rel, nil
+}
+
+// printDecision prints "+" for packed and "-" for skipped paths with the reason,
+// verbose adds how each involved mask is matched.
+func printDecision(w io.Writer, d vdf.MaskDecision, verbose bool) {
+	sign := "-"
+	if d.Included {
+		sign = "+"
+	}
+	fmt.Fprintf(w, "%s %s: %s\n", sign, d.Path, d.Reason())
+	if !verbose {
+		return
+	}
+	for _, r := range d.Rules() {
+		fmt.Fprintf(w, "    %s\n", r)
+		fmt.Fprintf(w, "      %s\n", r.Semantics())
+		fmt.Fprintf(w, "      regexp %s\n", r.Pattern)
+	}
+}
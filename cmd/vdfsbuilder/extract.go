@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/kirides/vdfsbuilder/vdf"
+)
+
+type overwritePolicy string
+
+const (
+	overwriteError  overwritePolicy = "error"
+	overwriteSkip   overwritePolicy = "skip"
+	overwriteAlways overwritePolicy = "always"
+)
+
+func (p *overwritePolicy) String() string { return string(*p) }
+func (p *overwritePolicy) Set(s string) error {
+	switch v := overwritePolicy(strings.ToLower(s)); v {
+	case overwriteError, overwriteSkip, overwriteAlways:
+		*p = v
+		return nil
+	}
+	return fmt.Errorf("must be one of %q, %q or %q", overwriteError, overwriteSkip, overwriteAlways)
+}
+
+func runExtract(args []string) int {
+	fset := flag.NewFlagSet("extract", flag.ContinueOnError)
+	outDir := fset.String("o", ".", "output directory")
+	dryRun := fset.Bool("dry-run", false, "only print what would be extracted")
+	overwrite := overwriteError
+	fset.Var(&overwrite, "overwrite", "what to do with existing files: \"error\", \"skip\" or \"always\"")
+	setUsage(fset, "extract [options] archive.vdf [pattern...]",
+		"patterns are matched case-insensitively against the full path",
+		"or, if they contain no \"/\", against the file name.")
+	if code, ok := parseFlags(fset, args); !ok {
+		return code
+	}
+	if fset.NArg() < 1 {
+		fset.Usage()
+		return exitUsage
+	}
+	patterns := fset.Args()[1:]
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid pattern %q. %v\n", p, err)
+			return exitUsage
+		}
+	}
+
+	r, err := vdf.Open(fset.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open %q. %v\n", fset.Arg(0), err)
+		return exitFailure
+	}
+	defer r.Close()
+
+	failed := false
+	for _, f := range r.Files {
+		if f.IsDir() || !matchesAny(patterns, f.Path) {
+			continue
+		}
+		if err := checkEntryPath(f.Path); err != nil {
+			fmt.Fprintf(os.Stderr, "refusing to extract %q. %v\n", f.Path, err)
+			failed = true
+			continue
+		}
+		dst := filepath.Join(*outDir, filepath.FromSlash(f.Path))
+		if *dryRun {
+			fmt.Printf("would extract %s (%d bytes)\n", dst, f.Size)
+			continue
+		}
+		written, err := extractFile(f, dst, overwrite)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to extract %q. %v\n", f.Path, err)
+			failed = true
+			continue
+		}
+		if written {
+			fmt.Printf("extracted %s\n", dst)
+		} else {
+			fmt.Printf("skipped %s (exists)\n", dst)
+		}
+	}
+	if failed {
+		return exitFailure
+	}
+	return exitOK
+}
+
+func matchesAny(patterns []string, p string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	p = strings.ToUpper(p)
+	name := path.Base(p)
+	for _, pattern := range patterns {
+		pattern = strings.ToUpper(filepath.ToSlash(pattern))
+		subject := p
+		if !strings.Contains(pattern, "/") {
+			subject = name
+		}
+		if ok, _ := path.Match(pattern, subject); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkEntryPath makes sure an entry can not escape the output directory,
+// e.g. through entry names like ".." or "C:".
+func checkEntryPath(p string) error {
+	if !fs.ValidPath(p) || p == "." {
+		return errors.New("not a valid relative path")
+	}
+	if strings.ContainsAny(p, `\:`) {
+		return errors.New("contains a reserved character")
+	}
+	if !filepath.IsLocal(filepath.FromSlash(p)) {
+		return errors.New("not a local path")
+	}
+	return nil
+}
+
+// extractFile writes the content of f to dst. Existing files are replaced
+// atomically, a failed copy leaves them untouched.
+func extractFile(f *vdf.File, dst string, overwrite overwritePolicy) (bool, error) {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return false, err
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		switch overwrite {
+		case overwriteSkip:
+			return false, nil
+		case overwriteError:
+			return false, &fs.PathError{Op: "extract", Path: dst, Err: fs.ErrExist}
+		}
+	}
+	out, err := vdf.CreateAtomic(dst)
+	if err != nil {
+		return false, err
+	}
+	defer out.Abort()
+	n, err := io.Copy(out, f.Open())
+	if err != nil {
+		return false, err
+	}
+	if n != int64(f.Size) {
+		return false, fmt.Errorf("archive ends after %d of %d bytes", n, f.Size)
+	}
+	return true, out.Commit(false)
+}
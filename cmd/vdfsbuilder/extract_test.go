@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/kirides/vdfsbuilder/vdf"
+)
+
+type rawEntry struct {
+	name, data string
+}
+
+// writeRawArchive writes a V2 archive with the given files in its root directory,
+// without the checks of the writer, to test archives it would never create.
+func writeRawArchive(t *testing.T, path string, entries []rawEntry) {
+	t.Helper()
+	type params struct{ EntryCount, FileCount, TimeStamp, DataSize, TableOffset, EntrySize uint32 }
+	type entry struct {
+		Name                         vdf.EntryName
+		Offset, Size, Flags, Attribs uint32
+	}
+	const headerSize, entrySize = 256 + 16 + 6*4, 64 + 4*4
+
+	var version vdf.Version
+	copy(version[:], "PSVDSC_V2.00\n\r\n\r")
+	table := make([]entry, len(entries))
+	var data bytes.Buffer
+	offset := uint32(headerSize + entrySize*len(entries))
+	for i, e := range entries {
+		table[i] = entry{Offset: offset + uint32(data.Len()), Size: uint32(len(e.data)), Attribs: uint32(vdf.EntryAttribArchive)}
+		// the writer pads names with spaces
+		copy(table[i].Name[:], bytes.Repeat([]byte(" "), len(table[i].Name)))
+		copy(table[i].Name[:], e.name)
+		data.WriteString(e.data)
+	}
+	table[len(table)-1].Flags = uint32(vdf.EntryFlagLastEntry)
+
+	var b bytes.Buffer
+	b.Write(make([]byte, 256)) // comment
+	b.Write(version[:])
+	binary.Write(&b, binary.LittleEndian, params{uint32(len(entries)), uint32(len(entries)), 0, uint32(data.Len()), headerSize, entrySize})
+	binary.Write(&b, binary.LittleEndian, table)
+	b.Write(data.Bytes())
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// listFiles returns the slash separated paths of all files below root.
+func listFiles(t *testing.T, root string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		files = append(files, filepath.ToSlash(rel))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(files)
+	return files
+}
+
+func TestExtractRefusesEscapingEntries(t *testing.T) {
+	for _, name := range []string{"..", "../x", "C:", `a\..\b`, ""} {
+		root := t.TempDir()
+		archive := filepath.Join(root, "evil.vdf")
+		writeRawArchive(t, archive, []rawEntry{{"OK.TXT", "ok"}, {name, "evil"}})
+
+		out := filepath.Join(root, "out")
+		if code := run([]string{"extract", "-o", out, archive}); code != exitFailure {
+			t.Errorf("%q: expected exit code %d, got %d", name, exitFailure, code)
+		}
+		if got, want := listFiles(t, root), []string{"evil.vdf", "out/OK.TXT"}; !slices.Equal(got, want) {
+			t.Errorf("%q: expected only %v to exist, got %v", name, want, got)
+		}
+	}
+}
+
+func TestExtractKeepsExistingFilesOnFailure(t *testing.T) {
+	root := t.TempDir()
+	archive := filepath.Join(root, "test.vdf")
+	writeRawArchive(t, archive, []rawEntry{{"A.TXT", "new content"}})
+	out := filepath.Join(root, "out")
+	if err := os.MkdirAll(out, 0755); err != nil {
+		t.Fatal(err)
+	}
+	existing := filepath.Join(out, "A.TXT")
+	if err := os.WriteFile(existing, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := vdf.Open(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	// the data of A.TXT is cut off after opening
+	if err := os.Truncate(archive, r.Size()-4); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := extractFile(r.Files[0], existing, overwriteAlways); err == nil {
+		t.Fatal("expected an error for a truncated archive")
+	}
+
+	content, err := os.ReadFile(existing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "original" {
+		t.Errorf("expected the existing file to be kept, got %q", content)
+	}
+	if got := listFiles(t, out); !slices.Equal(got, []string{"A.TXT"}) {
+		t.Errorf("expected no temporary files to be left, got %v", got)
+	}
+
+	// a complete copy replaces it
+	r.Close()
+	writeRawArchive(t, archive, []rawEntry{{"A.TXT", "new content"}})
+	if code := run([]string{"extract", "-o", out, "-overwrite", "always", archive}); code != exitOK {
+		t.Fatalf("expected exit code %d, got %d", exitOK, code)
+	}
+	if content, _ := os.ReadFile(existing); string(content) != "new content" {
+		t.Errorf("expected the file to be replaced, got %q", content)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kirides/vdfsbuilder/vdf"
+)
+
+func runInfo(args []string) int {
+	fset := flag.NewFlagSet("info", flag.ContinueOnError)
+	setUsage(fset, "info archive.vdf...")
+	if code, ok := parseFlags(fset, args); !ok {
+		return code
+	}
+	if fset.NArg() < 1 {
+		fset.Usage()
+		return exitUsage
+	}
+
+	result := exitOK
+	for i, archive := range fset.Args() {
+		if i > 0 {
+			fmt.Println()
+		}
+		r, err := vdf.Open(archive)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open %q. %v\n", archive, err)
+			result = exitFailure
+			continue
+		}
+		printInfo(archive, r)
+		r.Close()
+	}
+	return result
+}
+
+func printInfo(name string, r *vdf.Reader) {
+	var dirs int
+	var total int64
+	var largest *vdf.File
+	for _, f := range r.Files {
+		if f.IsDir() {
+			dirs++
+			continue
+		}
+		total += int64(f.Size)
+		if largest == nil || f.Size > largest.Size {
+			largest = f
+		}
+	}
+
+	fmt.Printf("Archive:   %s (%s)\n", name, vdf.FormatSize(r.Size()))
+	fmt.Printf("Format:    %s\n", r.Header.Format())
+	printHeader(os.Stdout, r.Header)
+	fmt.Printf("Dirs:      %d\n", dirs)
+	fmt.Printf("Content:   %s in files", vdf.FormatSize(total))
+	if saved := total - r.StoredSize(); saved > 0 {
+		fmt.Printf(", %s saved by deduplication", vdf.FormatSize(saved))
+	}
+	fmt.Println()
+	if largest != nil {
+		fmt.Printf("Largest:   %s (%s)\n", largest.Path, vdf.FormatSize(int64(largest.Size)))
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const scriptTemplate = `[BEGINVDF]
+Comment=%s
+BaseDir=.\
+VDFName=.\%s
+; optional, "3" writes PSVDSC_V3.00 archives with 64 bit sizes (requires engine extensions)
+;Format=2
+; optional, split into volumes of at most this size each
+;MaxVolumeSize=2G
+; optional, fixed UTC timestamp for reproducible builds
+;Timestamp=2021-11-28 12:31:40
+[FILES]
+_WORK\* -r
+[EXCLUDE]
+DESKTOP.INI -r
+THUMBS.DB -r
+[INCLUDE]
+[ENDVDF]
+`
+
+func runInit(args []string) int {
+	fset := flag.NewFlagSet("init", flag.ContinueOnError)
+	vdfName := fset.String("name", "", "name of the archive, defaults to the name of the script with a .vdf extension")
+	comment := fset.String("comment", "", "comment of the archive")
+	force := fset.Bool("f", false, "overwrite an existing script")
+	setUsage(fset, "init [options] [script.vm]",
+		"creates a script packing everything below _WORK, \"Mod.vm\" by default.")
+	if code, ok := parseFlags(fset, args); !ok {
+		return code
+	}
+	if fset.NArg() > 1 {
+		fset.Usage()
+		return exitUsage
+	}
+	script := "Mod.vm"
+	if fset.NArg() == 1 {
+		script = fset.Arg(0)
+	}
+	name := *vdfName
+	if name == "" {
+		base := filepath.Base(script)
+		name = strings.TrimSuffix(base, filepath.Ext(base)) + ".vdf"
+	}
+	if strings.ContainsAny(name+*comment, "\r\n") {
+		fmt.Fprintln(os.Stderr, "name and comment must not contain line breaks, use %%N for a new line in the comment")
+		return exitUsage
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if *force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(script, flags, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create %q. %v\n", script, err)
+		return exitFailure
+	}
+	if _, err := fmt.Fprintf(f, scriptTemplate, *comment, name); err != nil {
+		f.Close()
+		fmt.Fprintf(os.Stderr, "failed to write %q. %v\n", script, err)
+		return exitFailure
+	}
+	if err := f.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write %q. %v\n", script, err)
+		return exitFailure
+	}
+	fmt.Printf("created %s, build it with \"%s build %s\"\n", script, invocation(), script)
+	return exitOK
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kirides/vdfsbuilder"
+	"github.com/kirides/vdfsbuilder/vdf"
+)
+
+func runLint(args []string) int {
+	fset := flag.NewFlagSet("lint", flag.ContinueOnError)
+	baseDir := fset.String("b", "", "base directory (substitution for \".\\\")")
+	setUsage(fset, "lint [options] script.vm...",
+		"checks the [FILES], [EXCLUDE] and [INCLUDE] masks against the files below BaseDir.",
+		"warns about masks matching nothing, excludes that never exclude anything, shadowed includes",
+		"and redundant masks, overlapping masks are noted. exits with 1 if there are warnings.")
+	if code, ok := parseFlags(fset, args); !ok {
+		return code
+	}
+	if fset.NArg() < 1 {
+		fset.Usage()
+		return exitUsage
+	}
+	scripts, err := vdfsbuilder.ExpandScripts(fset.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitUsage
+	}
+
+	result := exitOK
+	var warnings, notes int
+	for _, script := range scripts {
+		vm, err := vdf.ParseVM(script)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to parse input %q. %v\n", script, err)
+			result = exitFailure
+			continue
+		}
+		if *baseDir != "" {
+			vm.BaseDir = *baseDir
+		}
+		vdfsbuilder.SanitizeVM(vm)
+
+		findings, err := vm.Lint(context.Background())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to lint %q. %v\n", script, err)
+			result = exitFailure
+			continue
+		}
+		for _, f := range findings {
+			fmt.Printf("%s:%d: %s: [%s] %q %s\n", script, f.Rule.Line, f.Level, f.Rule.Section, f.Rule.Mask, f.Message)
+			if f.Level == vdf.LintWarning {
+				warnings++
+			} else {
+				notes++
+			}
+		}
+	}
+	fmt.Printf("%d warnings, %d notes\n", warnings, notes)
+	if warnings != 0 {
+		result = exitFailure
+	}
+	return result
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kirides/vdfsbuilder/vdf"
+)
+
+func runList(args []string) int {
+	fset := flag.NewFlagSet("list", flag.ContinueOnError)
+	long := fset.Bool("l", false, "long listing with header, size, offset, flags and attributes")
+	tree := fset.Bool("tree", false, "print entries as a tree")
+	format := fset.String("format", "plain", "output format: \"plain\", \"json\" or \"csv\"")
+	setUsage(fset, "list [options] archive.vdf")
+	if code, ok := parseFlags(fset, args); !ok {
+		return code
+	}
+	if fset.NArg() != 1 {
+		fset.Usage()
+		return exitUsage
+	}
+
+	r, err := vdf.Open(fset.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open %q. %v\n", fset.Arg(0), err)
+		return exitFailure
+	}
+	defer r.Close()
+
+	switch strings.ToLower(*format) {
+	case "plain":
+		if *long || *tree {
+			printHeader(os.Stdout, r.Header)
+			fmt.Println()
+		}
+		if *tree {
+			printTree(os.Stdout, r, *long)
+		} else if *long {
+			printLong(os.Stdout, r)
+		} else {
+			for _, f := range r.Files {
+				fmt.Println(displayPath(f))
+			}
+		}
+	case "json":
+		err = writeJSON(os.Stdout, r)
+	case "csv":
+		err = writeCSV(os.Stdout, r)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", *format)
+		return exitUsage
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write listing. %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+func displayPath(f *vdf.File) string {
+	if f.IsDir() {
+		return f.Path + "/"
+	}
+	return f.Path
+}
+
+func printHeader(w io.Writer, h vdf.Header) {
+	fmt.Fprintf(w, "Comment:   %s\n", strings.ReplaceAll(h.Comment.String(), "\r\n", "\n           "))
+	fmt.Fprintf(w, "Version:   %s\n", h.Version)
+	if t, err := h.ParseTime(); err != nil {
+		fmt.Fprintf(w, "Timestamp: %v\n", err)
+	} else {
+		fmt.Fprintf(w, "Timestamp: %s\n", t.Format(time.DateTime))
+	}
+	fmt.Fprintf(w, "Entries:   %d (%d files)\n", h.Params.EntryCount, h.Params.FileCount)
+	fmt.Fprintf(w, "Data size: %d\n", h.Params.DataSize)
+}
+
+func printLong(w io.Writer, r *vdf.Reader) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Index\tFlags\tAttr\tSize\tOffset\t Path")
+	for _, f := range r.Files {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%d\t %s\n", f.Index, f.Flags, f.Attribs, f.Size, f.Offset, displayPath(f))
+	}
+	tw.Flush()
+}
+
+func printTree(w io.Writer, r *vdf.Reader, long bool) {
+	if len(r.Files) == 0 {
+		return
+	}
+	var walk func(start int, indent string)
+	walk = func(start int, indent string) {
+		for i := start; i < len(r.Files); i++ {
+			f := r.Files[i]
+			last := f.Flags&vdf.EntryFlagLastEntry != 0
+			branch, childIndent := "├── ", indent+"│   "
+			if last {
+				branch, childIndent = "└── ", indent+"    "
+			}
+			name := f.Name.String()
+			if f.IsDir() {
+				fmt.Fprintf(w, "%s%s%s/\n", indent, branch, name)
+				walk(int(f.Offset), childIndent)
+			} else if long {
+				fmt.Fprintf(w, "%s%s%s (%d bytes @ %d, %s)\n", indent, branch, name, f.Size, f.Offset, f.Attribs)
+			} else {
+				fmt.Fprintf(w, "%s%s%s\n", indent, branch, name)
+			}
+			if last {
+				return
+			}
+		}
+	}
+	fmt.Fprintln(w, ".")
+	walk(0, "")
+}
+
+// jsonEntry holds flags and attributes as letters, the same as the -l and CSV output.
+type jsonEntry struct {
+	Index   int    `json:"index"`
+	Path    string `json:"path"`
+	Dir     bool   `json:"dir"`
+	Size    uint64 `json:"size"`
+	Offset  uint64 `json:"offset"`
+	Flags   string `json:"flags"`
+	Attribs string `json:"attribs"`
+}
+
+type jsonListing struct {
+	Comment    vdf.Comment `json:"comment"`
+	Version    vdf.Version `json:"version"`
+	Timestamp  time.Time   `json:"timestamp"`
+	EntryCount uint32      `json:"entryCount"`
+	FileCount  uint32      `json:"fileCount"`
+	DataSize   uint64      `json:"dataSize"`
+	Entries    []jsonEntry `json:"entries"`
+}
+
+func writeJSON(w io.Writer, r *vdf.Reader) error {
+	l := jsonListing{
+		Comment:    r.Header.Comment,
+		Version:    r.Header.Version,
+		Timestamp:  r.Header.Time(),
+		EntryCount: r.Header.Params.EntryCount,
+		FileCount:  r.Header.Params.FileCount,
+		DataSize:   uint64(r.Header.Params.DataSize),
+		Entries:    make([]jsonEntry, len(r.Files)),
+	}
+	for i, f := range r.Files {
+		l.Entries[i] = jsonEntry{
+			Index:   f.Index,
+			Path:    f.Path,
+			Dir:     f.IsDir(),
+			Size:    uint64(f.Size),
+			Offset:  uint64(f.Offset),
+			Flags:   f.Flags.String(),
+			Attribs: f.Attribs.String(),
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(l)
+}
+
+func writeCSV(w io.Writer, r *vdf.Reader) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"index", "path", "dir", "size", "offset", "flags", "attribs"})
+	for _, f := range r.Files {
+		cw.Write([]string{
+			strconv.Itoa(f.Index),
+			f.Path,
+			strconv.FormatBool(f.IsDir()),
+			strconv.FormatUint(uint64(f.Size), 10),
+			strconv.FormatUint(uint64(f.Offset), 10),
+			f.Flags.String(),
+			f.Attribs.String(),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/kirides/vdfsbuilder/vdf"
+)
+
+func openRawArchive(t *testing.T, entries []rawEntry) *vdf.Reader {
+	t.Helper()
+	archive := filepath.Join(t.TempDir(), "test.vdf")
+	writeRawArchive(t, archive, entries)
+	r, err := vdf.Open(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestWriteJSON(t *testing.T) {
+	r := openRawArchive(t, []rawEntry{{"A.TXT", "a"}, {"B.TXT", "bb"}})
+	var b bytes.Buffer
+	if err := writeJSON(&b, r); err != nil {
+		t.Fatal(err)
+	}
+
+	var l struct {
+		Version   string
+		FileCount uint32
+		DataSize  uint64
+		Entries   []jsonEntry
+	}
+	if err := json.Unmarshal(b.Bytes(), &l); err != nil {
+		t.Fatalf("invalid JSON. %v\n%s", err, b.String())
+	}
+	if l.Version != "PSVDSC_V2.00" || l.FileCount != 2 || l.DataSize != 3 {
+		t.Errorf("unexpected header %+v", l)
+	}
+	want := []jsonEntry{
+		{Index: 0, Path: "A.TXT", Size: 1, Offset: 456, Flags: "--", Attribs: "---A"},
+		{Index: 1, Path: "B.TXT", Size: 2, Offset: 457, Flags: "-L", Attribs: "---A"},
+	}
+	if !slices.Equal(l.Entries, want) {
+		t.Errorf("expected entries %+v, got %+v", want, l.Entries)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	r := openRawArchive(t, []rawEntry{{"A.TXT", "a"}, {"B.TXT", "bb"}})
+	var b bytes.Buffer
+	if err := writeCSV(&b, r); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"index", "path", "dir", "size", "offset", "flags", "attribs"},
+		{"0", "A.TXT", "false", "1", "456", "--", "---A"},
+		{"1", "B.TXT", "false", "2", "457", "-L", "---A"},
+	}
+	if !slices.EqualFunc(records, want, slices.Equal) {
+		t.Errorf("expected %v, got %v", want, records)
+	}
+}
@@ -1,16 +1,11 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
-	"log"
 	"os"
 	"runtime"
-	"strings"
-	"time"
-
-	"github.com/kirides/vdfsbuilder"
-	"github.com/kirides/vdfsbuilder/vdf"
 )
 
 func invocation() string {
@@ -20,69 +15,108 @@ func invocation() string {
 	return "./vdfsbuilder"
 }
 
-func main() {
-	outFile := flag.String("o", "", "override output filepath")
-	baseDir := flag.String("b", "", "base directory (substitution for \".\\\")")
-	tsOverrideStr := flag.String("ts", "", "a Timestamp in the format \"YYYY-MM-dd HH:mm:ss\". E.g \"2021-11-28 12:31:40\"")
-	// tsIsUtc := flag.Bool("utc", true, "if the \"ts\" argument should be interpreted as UTC time.")
-	log.SetOutput(os.Stdout)
+// Exit codes shared by all commands.
+const (
+	exitOK          = 0   // success
+	exitFailure     = 1   // the command failed, found problems or differences
+	exitUsage       = 2   // invalid arguments or flags
+	exitInterrupted = 130 // stopped by SIGINT or SIGTERM
+)
 
-	flag.Usage = func() {
-		fmt.Println("example:")
-		fmt.Printf("%s [options] *.vm\n", invocation())
-		fmt.Println()
-		fmt.Println("options:")
-		flag.PrintDefaults()
-	}
-	flag.Parse()
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
 
-	args := flag.Args()
+var commands = []command{
+	{"build", "build an archive from a script", runBuild},
+	{"list", "list the entries of an archive", runList},
+	{"extract", "extract files from an archive", runExtract},
+	{"verify", "check archives for structural problems", runVerify},
+	{"diff", "compare the entries of two archives", runDiff},
+	{"info", "print the header and statistics of archives", runInfo},
+	{"init", "create a script template", runInit},
+	{"explain", "show which masks of a script include or exclude paths", runExplain},
+	{"lint", "find masks of scripts without effect", runLint},
+}
 
-	if len(args) < 1 {
-		flag.PrintDefaults()
-		os.Exit(1)
-		return
-	}
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
 
-	vmTimestamp := time.Now()
-	if *tsOverrideStr != "" {
-		location := time.Local
-		if true /* *tsIsUtc */ {
-			location = time.UTC
-		}
-		parsed, err := time.ParseInLocation("2006-01-02 15:04:05", *tsOverrideStr, location)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to parse %q flag. %v", *tsOverrideStr, err)
-			os.Exit(2)
-			return
-		}
-		fmt.Fprintf(os.Stdout, "Override: Timestamp set to %q (%s)\n", parsed.Format("2006-01-02 15:04:05"), location)
-		vmTimestamp = parsed
+func run(args []string) int {
+	if len(args) == 0 {
+		usage()
+		return exitUsage
 	}
-
-	vm, err := vdf.ParseVM(args[0])
-	if err != nil {
-		log.Fatalf("failed to parse input. %v", err)
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		if len(args) > 1 {
+			if c, ok := findCommand(args[1]); ok {
+				return c.run([]string{"-h"})
+			}
+			fmt.Fprintf(os.Stderr, "unknown command %q\n", args[1])
+			return exitUsage
+		}
+		usage()
+		return exitOK
 	}
-	// allow for custom base directory
-	if *baseDir != "" {
-		vm.BaseDir = *baseDir
+	if c, ok := findCommand(args[0]); ok {
+		return c.run(args[1:])
 	}
+	// "vdfsbuilder [options] script.vm" predates the subcommands
+	return runBuild(args)
+}
 
-	vm.VDFName = strings.TrimPrefix(vm.VDFName, `.\`)
-
-	vdfsbuilder.SanitizeVM(vm)
-
-	if *outFile != "" {
-		vm.VDFName = *outFile
+func findCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
 	}
+	return command{}, false
+}
 
-	vm.Timestamp = vmTimestamp
+func usage() {
+	fmt.Println("usage:")
+	fmt.Printf("%s <command> [options] [arguments]\n", invocation())
+	fmt.Printf("%s [options] script.vm (same as build)\n", invocation())
+	fmt.Println()
+	fmt.Println("commands:")
+	for _, c := range commands {
+		fmt.Printf("  %-8s %s\n", c.name, c.summary)
+	}
+	fmt.Println()
+	fmt.Printf("run \"%s help <command>\" for the options of a command.\n", invocation())
+	fmt.Println("exit codes: 0 success, 1 failure, problems or differences found, 2 invalid usage, 130 interrupted")
+}
 
-	wd, _ := os.Getwd()
-	fmt.Fprintf(os.Stdout, "working directory: %q\n", wd)
+// setUsage sets the help output of a command, synopsis is printed after the invocation.
+func setUsage(fset *flag.FlagSet, synopsis string, notes ...string) {
+	fset.Usage = func() {
+		fmt.Println("example:")
+		fmt.Printf("%s %s\n", invocation(), synopsis)
+		if len(notes) != 0 {
+			fmt.Println()
+			for _, n := range notes {
+				fmt.Println(n)
+			}
+		}
+		fmt.Println()
+		fmt.Println("options:")
+		fset.PrintDefaults()
+	}
+}
 
-	if err := vm.Execute(); err != nil {
-		log.Fatalf("failed to execute %q. %v", args[0], err)
+// parseFlags parses args into fset. If the command should not
+// continue, it returns false and the exit code to use.
+func parseFlags(fset *flag.FlagSet, args []string) (int, bool) {
+	if err := fset.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, false
+		}
+		return exitUsage, false
 	}
+	return exitOK, true
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	useTempCacheDir(t)
+	dir, script := writeTestScript(t)
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"no arguments", nil, exitUsage},
+		{"help", []string{"help"}, exitOK},
+		{"help flag", []string{"-h"}, exitOK},
+		{"help for a command", []string{"help", "list"}, exitOK},
+		{"help for an unknown command", []string{"help", "bogus"}, exitUsage},
+		{"unknown flag", []string{"list", "-bogus", "x.vdf"}, exitUsage},
+		{"missing argument", []string{"list"}, exitUsage},
+		{"invalid worker count", []string{"build", "-j", "0", script}, exitUsage},
+		{"missing archive", []string{"list", filepath.Join(dir, "missing.vdf")}, exitFailure},
+		{"legacy build", []string{script}, exitOK},
+		{"legacy build with options", []string{"-o", filepath.Join(dir, "Other.vdf"), script}, exitOK},
+	}
+	for _, tt := range tests {
+		if got := run(tt.args); got != tt.want {
+			t.Errorf("%s: expected exit code %d, got %d", tt.name, tt.want, got)
+		}
+	}
+
+	for _, name := range []string{"Test.vdf", "Other.vdf"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected the legacy build to write %s. %v", name, err)
+		}
+	}
+}
+
+// writeTestScript writes a script packing a single file, its archive is
+// written to dir/Test.vdf.
+func writeTestScript(t *testing.T) (dir, script string) {
+	t.Helper()
+	dir = t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "_work"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "_work", "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	script = filepath.Join(dir, "test.vm")
+	// absolute paths, relative ones are resolved against the working directory
+	content := "[BEGINVDF]\r\nBaseDir=" + dir + string(filepath.Separator) + "\r\nVDFName=" + filepath.Join(dir, "Test.vdf") +
+		"\r\nTimestamp=2021-11-28 12:31:40\r\n[FILES]\r\n_work\\* -r\r\n[ENDVDF]\r\n"
+	if err := os.WriteFile(script, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir, script
+}
+
+// useTempCacheDir points os.UserCacheDir to a temporary directory.
+func useTempCacheDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+	t.Setenv("LocalAppData", dir)
+	t.Setenv("HOME", dir)
+}
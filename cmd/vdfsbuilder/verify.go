@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kirides/vdfsbuilder/vdf"
+)
+
+func runVerify(args []string) int {
+	fset := flag.NewFlagSet("verify", flag.ContinueOnError)
+	quiet := fset.Bool("q", false, "only print problems")
+	setUsage(fset, "verify [options] archive.vdf...")
+	if code, ok := parseFlags(fset, args); !ok {
+		return code
+	}
+	if fset.NArg() < 1 {
+		fset.Usage()
+		return exitUsage
+	}
+
+	result := exitOK
+	for _, archive := range fset.Args() {
+		problems, err := vdf.VerifyFile(archive)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to verify %q. %v\n", archive, err)
+			result = exitFailure
+			continue
+		}
+		for _, p := range problems {
+			fmt.Fprintf(os.Stdout, "%s: %s\n", archive, p)
+		}
+		if len(problems) != 0 {
+			fmt.Fprintf(os.Stdout, "%s: %d problem(s) found\n", archive, len(problems))
+			result = exitFailure
+		} else if !*quiet {
+			fmt.Fprintf(os.Stdout, "%s: OK\n", archive)
+		}
+	}
+	return result
+}
@@ -0,0 +1,34 @@
+package vdfsbuilder
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/kirides/vdfsbuilder/vdf"
+)
+
+// Depfile returns a Makefile rule as understood by make and ninja, with the
+// volumes built by vm as targets and the script and every packed file as prerequisites.
+func Depfile(vm *vdf.VM, script string) string {
+	var b strings.Builder
+	for i, v := range vm.Volumes() {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(escapeDepPath(v.Name))
+	}
+	b.WriteByte(':')
+	for _, input := range append([]string{script}, vm.Inputs()...) {
+		b.WriteString(" \\\n  ")
+		b.WriteString(escapeDepPath(input))
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
+
+// escapeDepPath escapes the characters make treats specially in file names.
+// Forward slashes work with make and ninja on every platform.
+func escapeDepPath(path string) string {
+	r := strings.NewReplacer(" ", `\ `, "#", `\#`, "$", "$$")
+	return r.Replace(filepath.ToSlash(path))
+}
@@ -0,0 +1,39 @@
+package vdfsbuilder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kirides/vdfsbuilder/vdf"
+)
+
+func TestDepfile(t *testing.T) {
+	base := t.TempDir()
+	for _, name := range []string{"a.txt", "sub/b $1 #2.txt"} {
+		p := filepath.Join(base, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vm := &vdf.VM{
+		BaseDir: base,
+		VDFName: filepath.Join(t.TempDir(), "my mod.vdf"),
+		Files:   []string{"* -r"},
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	slash := filepath.ToSlash
+	want := escapeDepPath(vm.VDFName) + `: \` + "\n" +
+		`  Mod.vm \` + "\n" +
+		`  ` + slash(base) + `/sub/b\ $$1\ \#2.txt \` + "\n" +
+		`  ` + slash(base) + `/a.txt` + "\n"
+	if got := Depfile(vm, "Mod.vm"); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+}
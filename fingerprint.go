@@ -0,0 +1,86 @@
+package vdfsbuilder
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kirides/vdfsbuilder/vdf"
+)
+
+const fingerprintHeader = "vdfsbuilder fingerprint 1"
+
+// FingerprintPath returns where the fingerprint of the archive vdfName is stored.
+// It is kept in the user's cache directory, next to the HashCache, so it never
+// ends up in the source tree masks like "* -r" pack.
+func FingerprintPath(vdfName string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(vdfName)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(dir, "vdfsbuilder", "fingerprint-"+hex.EncodeToString(sum[:8])+".txt"), nil
+}
+
+// UpToDate reports if the archive of vm was built from inputs with the given
+// fingerprint and its volumes were not replaced or modified since.
+func UpToDate(vm *vdf.VM, fingerprint string) bool {
+	path, err := FingerprintPath(vm.VDFName)
+	if err != nil {
+		return false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	if !s.Scan() || s.Text() != fingerprintHeader || !s.Scan() || s.Text() != fingerprint {
+		return false
+	}
+	volumes := 0
+	for s.Scan() {
+		// <size> <modification time> <volume>
+		fields := strings.SplitN(s.Text(), " ", 3)
+		if len(fields) != 3 {
+			return false
+		}
+		info, err := os.Stat(fields[2])
+		if err != nil || fields[0] != strconv.FormatInt(info.Size(), 10) || fields[1] != strconv.FormatInt(info.ModTime().UnixNano(), 10) {
+			return false
+		}
+		volumes++
+	}
+	return s.Err() == nil && volumes > 0
+}
+
+// WriteFingerprint stores the fingerprint of the inputs of the archive just built by vm.
+func WriteFingerprint(vm *vdf.VM, fingerprint string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n%s\n", fingerprintHeader, fingerprint)
+	for _, v := range vm.Volumes() {
+		info, err := os.Stat(v.Name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "%d %d %s\n", info.Size(), info.ModTime().UnixNano(), v.Name)
+	}
+	path, err := FingerprintPath(vm.VDFName)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
@@ -0,0 +1,66 @@
+package vdfsbuilder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kirides/vdfsbuilder/vdf"
+)
+
+// useTempCacheDir points os.UserCacheDir to a temporary directory.
+func useTempCacheDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", dir)
+	t.Setenv("LocalAppData", dir)
+	t.Setenv("HOME", dir)
+}
+
+func TestUpToDate(t *testing.T) {
+	useTempCacheDir(t)
+	base := t.TempDir()
+	if err := os.WriteFile(filepath.Join(base, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outDir := t.TempDir()
+	vm := &vdf.VM{
+		BaseDir:   base,
+		VDFName:   filepath.Join(outDir, "test.vdf"),
+		Files:     []string{"* -r"},
+		Timestamp: time.Date(2021, 11, 28, 12, 31, 40, 0, time.UTC),
+	}
+	fingerprint, err := vm.Fingerprint(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if UpToDate(vm, fingerprint) {
+		t.Fatal("expected a missing archive to be out of date")
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFingerprint(vm, fingerprint); err != nil {
+		t.Fatal(err)
+	}
+	if !UpToDate(vm, fingerprint) {
+		t.Error("expected the archive to be up to date")
+	}
+	// nothing next to the archive a "* -r" mask could pack
+	if entries, err := os.ReadDir(outDir); err != nil || len(entries) != 1 {
+		t.Errorf("expected only the archive in %q, got %v. %v", outDir, entries, err)
+	}
+	if UpToDate(vm, fingerprint+"0") {
+		t.Error("expected a different fingerprint to be out of date")
+	}
+
+	// an archive replaced by a build without fingerprint is out of date
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(vm.VDFName, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if UpToDate(vm, fingerprint) {
+		t.Error("expected a modified archive to be out of date")
+	}
+}
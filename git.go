@@ -0,0 +1,465 @@
+package vdfsbuilder
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GitCommitTime returns the committer time of HEAD of the git repository
+// containing dir. The repository is read directly, git does not need to be installed.
+func GitCommitTime(dir string) (time.Time, error) {
+	repo, err := findGitDir(dir)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := repo.checkObjectFormat(); err != nil {
+		return time.Time{}, err
+	}
+	id, err := repo.resolve("HEAD")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to resolve HEAD. %w", err)
+	}
+	typ, data, err := repo.readObject(id, 0)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read commit %s. %w", id, err)
+	}
+	if typ != objCommit {
+		return time.Time{}, fmt.Errorf("HEAD %s is not a commit", id)
+	}
+	return parseCommitTime(data)
+}
+
+type gitRepo struct {
+	// gitDir holds HEAD, commonDir the objects and refs. They only differ for worktrees.
+	gitDir, commonDir string
+}
+
+func findGitDir(dir string) (*gitRepo, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p := filepath.Join(dir, ".git")
+		if fi, err := os.Stat(p); err == nil {
+			if !fi.IsDir() {
+				// worktrees and submodules use a file "gitdir: <path>"
+				content, err := os.ReadFile(p)
+				if err != nil {
+					return nil, err
+				}
+				target, ok := strings.CutPrefix(strings.TrimSpace(string(content)), "gitdir: ")
+				if !ok {
+					return nil, fmt.Errorf("%q is not a valid .git file", p)
+				}
+				if !filepath.IsAbs(target) {
+					target = filepath.Join(dir, target)
+				}
+				p = target
+			}
+			repo := &gitRepo{gitDir: p, commonDir: p}
+			if common, err := os.ReadFile(filepath.Join(p, "commondir")); err == nil {
+				repo.commonDir = strings.TrimSpace(string(common))
+				if !filepath.IsAbs(repo.commonDir) {
+					repo.commonDir = filepath.Join(p, repo.commonDir)
+				}
+			}
+			return repo, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, errors.New("not inside a git repository")
+		}
+		dir = parent
+	}
+}
+
+// checkObjectFormat rejects repositories that do not use SHA-1 object ids,
+// e.g. those created with "git init --object-format=sha256".
+func (r *gitRepo) checkObjectFormat() error {
+	content, err := os.ReadFile(filepath.Join(r.commonDir, "config"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read the git config. %w", err)
+	}
+	section := ""
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			section = strings.ToLower(strings.Trim(line, "[] \t"))
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != "extensions" || !strings.EqualFold(strings.TrimSpace(key), "objectformat") {
+			continue
+		}
+		if format := strings.ToLower(strings.TrimSpace(value)); format != "sha1" {
+			return fmt.Errorf("the repository uses %s object ids, only sha1 is supported", format)
+		}
+	}
+	return nil
+}
+
+// resolve follows symbolic and packed refs down to an object id.
+func (r *gitRepo) resolve(ref string) (string, error) {
+	for range 10 {
+		content, err := os.ReadFile(filepath.Join(r.gitDir, filepath.FromSlash(ref)))
+		if errors.Is(err, os.ErrNotExist) && ref != "HEAD" {
+			content, err = os.ReadFile(filepath.Join(r.commonDir, filepath.FromSlash(ref)))
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			return r.packedRef(ref)
+		}
+		if err != nil {
+			return "", err
+		}
+		line := strings.TrimSpace(string(content))
+		if target, ok := strings.CutPrefix(line, "ref: "); ok {
+			ref = target
+			continue
+		}
+		return line, nil
+	}
+	return "", fmt.Errorf("too many levels of symbolic refs for %q", ref)
+}
+
+func (r *gitRepo) packedRef(ref string) (string, error) {
+	f, err := os.Open(filepath.Join(r.commonDir, "packed-refs"))
+	if err != nil {
+		return "", fmt.Errorf("ref %q not found. %w", ref, err)
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		id, name, ok := strings.Cut(s.Text(), " ")
+		if ok && name == ref {
+			return id, nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("ref %q not found", ref)
+}
+
+type objType int
+
+const (
+	objCommit   objType = 1
+	objTree     objType = 2
+	objBlob     objType = 3
+	objTag      objType = 4
+	objOfsDelta objType = 6
+	objRefDelta objType = 7
+)
+
+// readObject reads the object id, depth is the number of deltas already
+// followed to get to it.
+func (r *gitRepo) readObject(id string, depth int) (objType, []byte, error) {
+	if len(id) < 4 {
+		return 0, nil, fmt.Errorf("invalid object id %q", id)
+	}
+	content, err := os.ReadFile(filepath.Join(r.commonDir, "objects", id[:2], id[2:]))
+	if err == nil {
+		return parseLooseObject(content)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return 0, nil, err
+	}
+
+	raw, err := hex.DecodeString(id)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid object id %q", id)
+	}
+	indexes, err := filepath.Glob(filepath.Join(r.commonDir, "objects", "pack", "*.idx"))
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, idx := range indexes {
+		offset, ok, err := findInPackIndex(idx, raw)
+		if err != nil {
+			return 0, nil, err
+		}
+		if ok {
+			return r.readPacked(strings.TrimSuffix(idx, ".idx")+".pack", offset, depth)
+		}
+	}
+	return 0, nil, fmt.Errorf("object %s not found", id)
+}
+
+func parseLooseObject(content []byte) (objType, []byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return 0, nil, err
+	}
+	header, body, ok := bytes.Cut(data, []byte{0})
+	if !ok {
+		return 0, nil, errors.New("invalid loose object")
+	}
+	name, _, _ := strings.Cut(string(header), " ")
+	types := map[string]objType{"commit": objCommit, "tree": objTree, "blob": objBlob, "tag": objTag}
+	typ, ok := types[name]
+	if !ok {
+		return 0, nil, fmt.Errorf("unknown object type %q", name)
+	}
+	return typ, body, nil
+}
+
+// findInPackIndex looks up id in a version 2 pack index.
+func findInPackIndex(path string, id []byte) (int64, bool, error) {
+	idx, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false, err
+	}
+	const fanoutStart = 8
+	if len(idx) < fanoutStart+256*4 || !bytes.Equal(idx[:8], []byte{0xff, 't', 'O', 'c', 0, 0, 0, 2}) {
+		return 0, false, fmt.Errorf("%q is not a version 2 pack index", path)
+	}
+	fanout := func(i int) int {
+		if i < 0 {
+			return 0
+		}
+		return int(binary.BigEndian.Uint32(idx[fanoutStart+i*4:]))
+	}
+	count := fanout(255)
+	hashLen := len(id)
+	names := fanoutStart + 256*4
+	crcs := names + count*hashLen
+	offsets := crcs + count*4
+	large := offsets + count*4
+	if len(idx) < large {
+		return 0, false, fmt.Errorf("%q is truncated", path)
+	}
+
+	lo, hi := fanout(int(id[0])-1), fanout(int(id[0]))
+	for lo < hi {
+		mid := (lo + hi) / 2
+		switch c := bytes.Compare(idx[names+mid*hashLen:names+(mid+1)*hashLen], id); {
+		case c < 0:
+			lo = mid + 1
+		case c > 0:
+			hi = mid
+		default:
+			offset := binary.BigEndian.Uint32(idx[offsets+mid*4:])
+			if offset&0x80000000 == 0 {
+				return int64(offset), true, nil
+			}
+			i := large + int(offset&0x7fffffff)*8
+			if len(idx) < i+8 {
+				return 0, false, fmt.Errorf("%q is truncated", path)
+			}
+			return int64(binary.BigEndian.Uint64(idx[i:])), true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+func (r *gitRepo) readPacked(path string, offset int64, depth int) (objType, []byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+	return r.readPackEntry(f, offset, depth)
+}
+
+func (r *gitRepo) readPackEntry(f *os.File, offset int64, depth int) (objType, []byte, error) {
+	if depth > 64 {
+		return 0, nil, errors.New("delta chain too long")
+	}
+	br := bufio.NewReader(io.NewSectionReader(f, offset, 1<<62))
+	b, err := br.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	typ := objType(b >> 4 & 7)
+	for b&0x80 != 0 { // the size is not needed, zlib knows where the data ends
+		if b, err = br.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	var base []byte
+	var baseType objType
+	switch typ {
+	case objCommit, objTree, objBlob, objTag:
+		data, err := inflate(br)
+		return typ, data, err
+	case objOfsDelta:
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		rel := int64(b & 0x7f)
+		for b&0x80 != 0 {
+			if b, err = br.ReadByte(); err != nil {
+				return 0, nil, err
+			}
+			rel = (rel+1)<<7 | int64(b&0x7f)
+		}
+		// inflate the delta before reading the base, the buffered reader can not be shared
+		delta, err := inflate(br)
+		if err != nil {
+			return 0, nil, err
+		}
+		if baseType, base, err = r.readPackEntry(f, offset-rel, depth+1); err != nil {
+			return 0, nil, err
+		}
+		data, err := applyDelta(base, delta)
+		return baseType, data, err
+	case objRefDelta:
+		baseID := make([]byte, 20)
+		if _, err := io.ReadFull(br, baseID); err != nil {
+			return 0, nil, err
+		}
+		delta, err := inflate(br)
+		if err != nil {
+			return 0, nil, err
+		}
+		if baseType, base, err = r.readObject(hex.EncodeToString(baseID), depth+1); err != nil {
+			return 0, nil, err
+		}
+		data, err := applyDelta(base, delta)
+		return baseType, data, err
+	}
+	return 0, nil, fmt.Errorf("unknown pack object type %d", typ)
+}
+
+// maxObjectSize bounds what is inflated or rebuilt from a delta.
+// Only commits are read, which are far smaller.
+const maxObjectSize = 64 << 20
+
+func inflate(r io.Reader) ([]byte, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(io.LimitReader(zr, maxObjectSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxObjectSize {
+		return nil, fmt.Errorf("object exceeds the limit of %d bytes", maxObjectSize)
+	}
+	return data, nil
+}
+
+func applyDelta(base, delta []byte) ([]byte, error) {
+	errInvalid := errors.New("invalid delta")
+	pos := 0
+	varint := func() (int, error) {
+		v, shift := 0, 0
+		for {
+			if pos >= len(delta) || shift > 56 {
+				return 0, errInvalid
+			}
+			b := delta[pos]
+			pos++
+			v |= int(b&0x7f) << shift
+			shift += 7
+			if b&0x80 == 0 {
+				return v, nil
+			}
+		}
+	}
+	srcSize, err := varint()
+	if err != nil {
+		return nil, err
+	}
+	if srcSize != len(base) {
+		return nil, errInvalid
+	}
+	dstSize, err := varint()
+	if err != nil {
+		return nil, err
+	}
+	if dstSize > maxObjectSize {
+		return nil, fmt.Errorf("delta result of %d bytes exceeds the limit of %d bytes", dstSize, maxObjectSize)
+	}
+
+	out := make([]byte, 0, dstSize)
+	for pos < len(delta) {
+		op := delta[pos]
+		pos++
+		if op&0x80 == 0 {
+			// insert the next op bytes
+			n := int(op)
+			if n == 0 || pos+n > len(delta) || len(out)+n > dstSize {
+				return nil, errInvalid
+			}
+			out = append(out, delta[pos:pos+n]...)
+			pos += n
+			continue
+		}
+		// copy from base, offset and size are sparse little endian values
+		var offset, size int
+		for i := 0; i < 7; i++ {
+			if op&(1<<i) == 0 {
+				continue
+			}
+			if pos >= len(delta) {
+				return nil, errInvalid
+			}
+			if i < 4 {
+				offset |= int(delta[pos]) << (8 * i)
+			} else {
+				size |= int(delta[pos]) << (8 * (i - 4))
+			}
+			pos++
+		}
+		if size == 0 {
+			size = 0x10000
+		}
+		if offset+size > len(base) || len(out)+size > dstSize {
+			return nil, errInvalid
+		}
+		out = append(out, base[offset:offset+size]...)
+	}
+	if len(out) != dstSize {
+		return nil, errInvalid
+	}
+	return out, nil
+}
+
+// parseCommitTime reads the committer time of a commit object,
+// "committer Name <mail> 1700000000 +0100".
+func parseCommitTime(commit []byte) (time.Time, error) {
+	for _, line := range strings.Split(string(commit), "\n") {
+		if line == "" {
+			break // end of headers
+		}
+		rest, ok := strings.CutPrefix(line, "committer ")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest[strings.LastIndexByte(rest, '>')+1:])
+		if len(fields) < 1 {
+			break
+		}
+		sec, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid committer time %q", fields[0])
+		}
+		return time.Unix(sec, 0).UTC(), nil
+	}
+	return time.Time{}, errors.New("commit has no committer")
+}
@@ -0,0 +1,153 @@
+package vdfsbuilder
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func runGit(t *testing.T, dir string, env []string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_GLOBAL=/dev/null", "GIT_AUTHOR_NAME=a", "GIT_AUTHOR_EMAIL=a@example.com", "GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@example.com")
+	cmd.Env = append(cmd.Env, env...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s failed. %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+func TestGitCommitTime(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	repo := t.TempDir()
+	runGit(t, repo, nil, "init", "-q")
+	sub := filepath.Join(repo, "mod", "_work")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var want time.Time
+	for i, date := range []string{"2021-11-28T12:31:40+01:00", "2022-01-02T03:04:05-05:00"} {
+		content := strings.Repeat("line of text that stays the same\n", 50) + strings.Repeat("x", i+1)
+		if err := os.WriteFile(filepath.Join(sub, "a.d"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		runGit(t, repo, nil, "add", "-A")
+		runGit(t, repo, []string{"GIT_COMMITTER_DATE=" + date}, "commit", "-q", "-m", "commit "+date)
+		want, _ = time.Parse(time.RFC3339, date)
+	}
+
+	check := func(name string) {
+		t.Helper()
+		got, err := GitCommitTime(sub)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !got.Equal(want) {
+			t.Errorf("%s: expected %v, got %v", name, want, got)
+		}
+	}
+	check("loose objects")
+
+	// packed refs and objects, including deltas
+	runGit(t, repo, nil, "gc", "-q", "--aggressive")
+	check("packed objects")
+
+	runGit(t, repo, nil, "checkout", "-q", "--detach")
+	check("detached HEAD")
+
+	if _, err := GitCommitTime(t.TempDir()); err == nil {
+		t.Errorf("expected an error outside of a repository")
+	}
+}
+
+func TestApplyDelta(t *testing.T) {
+	base := []byte("hello world")
+	// source and target size 11, copy 6 bytes from offset 0, insert 5 bytes
+	delta := []byte{11, 11, 0x80 | 0x10, 6, 5, 'g', 'o', 'p', 'h', 'e'}
+	got, err := applyDelta(base, delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello gophe" {
+		t.Errorf("unexpected result %q", got)
+	}
+	if _, err := applyDelta(base, delta[:len(delta)-1]); err == nil {
+		t.Errorf("expected an error for a truncated delta")
+	}
+
+	// a target size of 1 TiB must fail without allocating it
+	huge := binary.AppendUvarint([]byte{11}, 1<<40)
+	if _, err := applyDelta(base, append(huge, 0x80|0x10, 6)); err == nil {
+		t.Errorf("expected an error for an oversized target")
+	}
+	// the ops produce more than the stated target size
+	if _, err := applyDelta(base, []byte{11, 2, 0x80 | 0x10, 6}); err == nil {
+		t.Errorf("expected an error for a target larger than stated")
+	}
+}
+
+func TestGitCommitTimeRejectsSHA256Repositories(t *testing.T) {
+	dir := t.TempDir()
+	gitDir := filepath.Join(dir, ".git")
+	if err := os.MkdirAll(gitDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := "[core]\n\trepositoryformatversion = 1\n[extensions]\n\tobjectFormat = sha256\n"
+	if err := os.WriteFile(filepath.Join(gitDir, "config"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GitCommitTime(dir); err == nil || !strings.Contains(err.Error(), "sha256") {
+		t.Errorf("expected an error naming the object format, got %v", err)
+	}
+}
+
+func TestReadObjectRejectsCyclicDeltas(t *testing.T) {
+	dir := t.TempDir()
+	packDir := filepath.Join(dir, "objects", "pack")
+	if err := os.MkdirAll(packDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	id := bytes.Repeat([]byte{0xab}, 20)
+
+	// a single ref delta whose base is the object itself
+	var pack bytes.Buffer
+	pack.WriteString("PACK\x00\x00\x00\x02\x00\x00\x00\x01")
+	pack.WriteByte(byte(objRefDelta) << 4)
+	pack.Write(id)
+	zw := zlib.NewWriter(&pack)
+	zw.Write([]byte{0, 0})
+	zw.Close()
+
+	idx := []byte{0xff, 't', 'O', 'c', 0, 0, 0, 2}
+	for i := range 256 {
+		count := uint32(0)
+		if i >= int(id[0]) {
+			count = 1
+		}
+		idx = binary.BigEndian.AppendUint32(idx, count)
+	}
+	idx = append(idx, id...)
+	idx = binary.BigEndian.AppendUint32(idx, 0)  // crc
+	idx = binary.BigEndian.AppendUint32(idx, 12) // offset
+	if err := os.WriteFile(filepath.Join(packDir, "p.pack"), pack.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(packDir, "p.idx"), idx, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := &gitRepo{gitDir: dir, commonDir: dir}
+	if _, _, err := repo.readObject(hex.EncodeToString(id), 0); err == nil || !strings.Contains(err.Error(), "delta chain too long") {
+		t.Errorf("expected an error for a cyclic delta chain, got %v", err)
+	}
+}
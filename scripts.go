@@ -0,0 +1,34 @@
+package vdfsbuilder
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ExpandScripts expands glob patterns like "*.vm" to the matching scripts,
+// shells on Windows leave this to the program. Arguments without pattern
+// characters are kept as they are, duplicates are removed.
+func ExpandScripts(args []string) ([]string, error) {
+	var scripts []string
+	seen := make(map[string]bool)
+	for _, arg := range args {
+		matches := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			var err error
+			if matches, err = filepath.Glob(arg); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q. %w", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no scripts match %q", arg)
+			}
+		}
+		for _, m := range matches {
+			if key := filepath.Clean(m); !seen[key] {
+				seen[key] = true
+				scripts = append(scripts, m)
+			}
+		}
+	}
+	return scripts, nil
+}
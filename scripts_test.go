@@ -0,0 +1,31 @@
+package vdfsbuilder
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestExpandScripts(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"Scripts.vm", "Textures.vm", "readme.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	scripts := filepath.Join(dir, "Scripts.vm")
+	textures := filepath.Join(dir, "Textures.vm")
+
+	got, err := ExpandScripts([]string{scripts, filepath.Join(dir, "*.vm"), "missing.vm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{scripts, textures, "missing.vm"}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	if _, err := ExpandScripts([]string{filepath.Join(dir, "*.d")}); err == nil {
+		t.Errorf("expected an error for a pattern without matches")
+	}
+}
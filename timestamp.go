@@ -0,0 +1,59 @@
+package vdfsbuilder
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kirides/vdfsbuilder/vdf"
+)
+
+// ResolveTimestamp sets vm.Timestamp for a reproducible build and
+// describes where it came from. In order of precedence it uses
+//   - override, a timestamp as understood by vdf.ParseTimestamp or "git"
+//     for the HEAD commit time of the repository containing vm.BaseDir
+//   - the Timestamp= of the script
+//   - $SOURCE_DATE_EPOCH
+//   - the current time
+//
+// Timestamps are converted to loc, whose wall clock ends up in the archive.
+func ResolveTimestamp(vm *vdf.VM, override string, loc *time.Location) (string, error) {
+	switch {
+	case strings.EqualFold(override, "git"):
+		ts, err := GitCommitTime(vm.BaseDir)
+		if err != nil {
+			return "", fmt.Errorf("failed to read the git HEAD commit time of %q. %w", vm.BaseDir, err)
+		}
+		vm.Timestamp = ts.In(loc)
+		return "git HEAD", nil
+	case override != "":
+		ts, err := vdf.ParseTimestamp(override, loc)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse the override. %w", err)
+		}
+		vm.Timestamp = ts
+		return "override", nil
+	case vm.ScriptTimestamp() != "":
+		// parsed again, the script is read before loc is known
+		ts, err := vdf.ParseTimestamp(vm.ScriptTimestamp(), loc)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse Timestamp= of the script. %w", err)
+		}
+		vm.Timestamp = ts
+		return "script", nil
+	case !vm.Timestamp.IsZero():
+		return "script", nil
+	}
+	if epoch, ok := os.LookupEnv("SOURCE_DATE_EPOCH"); ok && epoch != "" {
+		sec, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse SOURCE_DATE_EPOCH %q. %w", epoch, err)
+		}
+		vm.Timestamp = time.Unix(sec, 0).In(loc)
+		return "SOURCE_DATE_EPOCH", nil
+	}
+	vm.Timestamp = time.Now()
+	return "current time", nil
+}
@@ -0,0 +1,70 @@
+package vdfsbuilder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kirides/vdfsbuilder/vdf"
+)
+
+func TestResolveTimestampPrecedence(t *testing.T) {
+	script := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	t.Setenv("SOURCE_DATE_EPOCH", "1638102700")
+
+	tests := []struct {
+		name     string
+		script   time.Time
+		override string
+		want     time.Time
+		source   string
+	}{
+		{"override", script, "2021-11-28 12:31:40", time.Date(2021, 11, 28, 12, 31, 40, 0, time.UTC), "override"},
+		{"script", script, "", script, "script"},
+		{"environment", time.Time{}, "", time.Unix(1638102700, 0).UTC(), "SOURCE_DATE_EPOCH"},
+	}
+	for _, tt := range tests {
+		vm := &vdf.VM{Timestamp: tt.script}
+		source, err := ResolveTimestamp(vm, tt.override, time.UTC)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if source != tt.source || !vm.Timestamp.Equal(tt.want) {
+			t.Errorf("%s: expected %v from %s, got %v from %s", tt.name, tt.want, tt.source, vm.Timestamp, source)
+		}
+	}
+
+	t.Setenv("SOURCE_DATE_EPOCH", "soon")
+	if _, err := ResolveTimestamp(&vdf.VM{}, "", time.UTC); err == nil || !strings.Contains(err.Error(), "SOURCE_DATE_EPOCH") {
+		t.Errorf("expected an error naming SOURCE_DATE_EPOCH, got %v", err)
+	}
+	if _, err := ResolveTimestamp(&vdf.VM{}, "tomorrow", time.UTC); err == nil || !strings.Contains(err.Error(), "override") {
+		t.Errorf("expected an error naming the override, got %v", err)
+	}
+	if _, err := ResolveTimestamp(&vdf.VM{BaseDir: t.TempDir()}, "git", time.UTC); err == nil || !strings.Contains(err.Error(), "git") {
+		t.Errorf("expected an error naming git, got %v", err)
+	}
+}
+
+func TestResolveTimestampParsesScriptInLocation(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "test.vm")
+	if err := os.WriteFile(script, []byte("[BEGINVDF]\nTimestamp=2021-11-28 12:31:40\n[ENDVDF]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	vm, err := vdf.ParseVM(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	source, err := ResolveTimestamp(vm, "", loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2021, 11, 28, 12, 31, 40, 0, loc)
+	if source != "script" || !vm.Timestamp.Equal(want) || vm.Timestamp.Location() != loc {
+		t.Errorf("expected %v from script, got %v from %s", want, vm.Timestamp, source)
+	}
+}
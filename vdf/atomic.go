@@ -0,0 +1,95 @@
+package vdf
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+)
+
+// AtomicFile is written next to its destination and only renamed
+// into place by Commit, so a failed write never replaces a good file.
+type AtomicFile struct {
+	*os.File
+	dest string
+	done bool
+}
+
+// CreateAtomic creates a temporary file in the directory of dest.
+// It must be either committed or aborted.
+func CreateAtomic(dest string) (*AtomicFile, error) {
+	// not os.CreateTemp, its files are only readable by the owner,
+	// this way new files get the permissions the umask allows
+	for {
+		name := filepath.Join(filepath.Dir(dest), fmt.Sprintf(".%s.%d.tmp", filepath.Base(dest), rand.Uint32()))
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &AtomicFile{File: f, dest: dest}, nil
+	}
+}
+
+// Commit flushes the file to disk and renames it to its destination.
+// An existing destination passes its permissions on. If keepBackup is set,
+// it is renamed to "<dest>.bak" first, and restored if the rename fails.
+func (f *AtomicFile) Commit(keepBackup bool) error {
+	if err := f.flush(); err != nil {
+		return err
+	}
+	return f.rename(keepBackup)
+}
+
+// flush writes the file to disk and closes it, the part of Commit that
+// can fail without touching the destination.
+func (f *AtomicFile) flush() error {
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if info, err := os.Stat(f.dest); err == nil {
+		if err := f.Chmod(info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// rename moves the flushed file to its destination.
+func (f *AtomicFile) rename(keepBackup bool) error {
+	backedUp := false
+	if keepBackup {
+		err := os.Rename(f.dest, f.dest+".bak")
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		backedUp = err == nil
+	}
+	if err := os.Rename(f.Name(), f.dest); err != nil {
+		if backedUp {
+			if restoreErr := os.Rename(f.dest+".bak", f.dest); restoreErr != nil {
+				return fmt.Errorf("%w, failed to restore the backup. %w", err, restoreErr)
+			}
+		}
+		return err
+	}
+	f.done = true
+	if err := syncDir(filepath.Dir(f.dest)); err != nil {
+		return fmt.Errorf("failed to flush the directory. %w", err)
+	}
+	return nil
+}
+
+// Abort removes the temporary file, unless it was committed.
+func (f *AtomicFile) Abort() {
+	if f.done {
+		return
+	}
+	f.Close()
+	os.Remove(f.Name())
+	f.done = true
+}
@@ -0,0 +1,74 @@
+package vdf
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCommitKeepsPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are not supported")
+	}
+	dir := t.TempDir()
+	// what the umask leaves of 0666
+	probe := filepath.Join(dir, "probe")
+	f, err := os.OpenFile(probe, os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	info, err := os.Stat(probe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultMode := info.Mode().Perm()
+
+	commit := func(dest string) os.FileMode {
+		t.Helper()
+		f, err := CreateAtomic(dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Abort()
+		if err := f.Commit(false); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Mode().Perm()
+	}
+
+	dest := filepath.Join(dir, "new.vdf")
+	assertEqual(t, commit(dest), defaultMode)
+	if err := os.Chmod(dest, 0600); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, commit(dest), 0600)
+}
+
+func TestCommitRestoresBackupIfRenameFails(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "test.vdf")
+	if err := os.WriteFile(dest, []byte("previous"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := CreateAtomic(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Abort()
+	// the final rename fails without the temporary file
+	if err := os.Remove(f.Name()); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Commit(true); err == nil {
+		t.Fatal("expected the commit to fail")
+	}
+	assertFileContent(t, dest, []byte("previous"))
+	if _, err := os.Stat(dest + ".bak"); !os.IsNotExist(err) {
+		t.Errorf("expected the backup to be moved back, got %v", err)
+	}
+}
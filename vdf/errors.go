@@ -0,0 +1,69 @@
+package vdf
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// BuildPhase names the step of a build in which an error occurred.
+type BuildPhase string
+
+const (
+	// PhaseSearch is the traversal of BaseDir.
+	PhaseSearch BuildPhase = "search"
+	// PhaseRead is reading or hashing a source file.
+	PhaseRead BuildPhase = "read"
+	// PhaseWrite is writing to the output archive.
+	PhaseWrite BuildPhase = "write"
+)
+
+// BuildError records a failure while building an archive.
+// Several of them may be combined using errors.Join.
+type BuildError struct {
+	Phase BuildPhase
+	// Path is the file or directory the error relates to.
+	Path string
+	Err  error
+}
+
+func (e *BuildError) Error() string {
+	return fmt.Sprintf("failed to %s %q. %v", e.Phase, e.Path, e.Err)
+}
+
+func (e *BuildError) Unwrap() error { return e.Err }
+
+// SizeLimitError is returned when an archive would exceed the
+// sizes and offsets its format is able to store.
+type SizeLimitError struct {
+	Format Format
+	// Size is the size the archive would have.
+	Size  uint64
+	Limit uint64
+	// Largest lists the biggest directories and files of the archive.
+	Largest []Contributor
+}
+
+// Contributor is a directory or file contributing to the size of an archive.
+type Contributor struct {
+	Path string
+	Size int64
+	Dir  bool
+}
+
+func (e *SizeLimitError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "archive would be %s, but format %s can only address %s", FormatSize(int64(e.Size)), e.Format, FormatSize(int64(e.Limit)))
+	if len(e.Largest) != 0 {
+		sb.WriteString("\nlargest contributors:")
+		for _, c := range e.Largest {
+			p := c.Path
+			if c.Dir {
+				p += string(filepath.Separator)
+			}
+			fmt.Fprintf(&sb, "\n  %10s  %s", FormatSize(c.Size), p)
+		}
+	}
+	sb.WriteString("\nsplit the pack into several VDFs (MaxVolumeSize=) or use format 3 (Format=3)")
+	return sb.String()
+}
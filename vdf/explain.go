@@ -0,0 +1,121 @@
+package vdf
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+)
+
+// MaskRule is a mask of the [FILES], [EXCLUDE] or [INCLUDE] section of a script.
+type MaskRule struct {
+	// Section is "FILES", "EXCLUDE" or "INCLUDE".
+	Section string
+	// Line within the script, zero if the mask was not parsed from one.
+	Line int
+	// Index of the mask within its section.
+	Index int
+	Mask  string
+	// Pattern is the regular expression the mask is matched with.
+	Pattern string
+}
+
+func (r MaskRule) String() string {
+	if r.Line > 0 {
+		return fmt.Sprintf("[%s] line %d %q", r.Section, r.Line, r.Mask)
+	}
+	return fmt.Sprintf("[%s] mask %d %q", r.Section, r.Index+1, r.Mask)
+}
+
+// Semantics describes how the mask is matched against paths.
+func (r MaskRule) Semantics() string {
+	if isRecursiveMask(r.Mask) {
+		return `-r matches the end of the path in any directory, "*" and "?" also match "/" and whitespace`
+	}
+	return `matches the whole path below BaseDir, "*" and "?" match neither "/" nor whitespace`
+}
+
+// MaskDecision explains whether a path is packed and which masks decided it.
+type MaskDecision struct {
+	// Path is slash separated and relative to BaseDir.
+	Path     string
+	Included bool
+	// Include, Files and Exclude are the first masks of the sections
+	// matching Path, nil if none does. Exclude is only matched after Files.
+	Include, Files, Exclude *MaskRule
+}
+
+// Reason describes the decision in a sentence.
+func (d MaskDecision) Reason() string {
+	switch {
+	case d.Include != nil && d.Files == nil:
+		return fmt.Sprintf("included by %s although no [FILES] mask matched, [INCLUDE] overrides all other masks", d.Include)
+	case d.Include != nil && d.Exclude != nil:
+		return fmt.Sprintf("re-included by %s although excluded by %s, [INCLUDE] overrides all other masks", d.Include, d.Exclude)
+	case d.Include != nil:
+		return fmt.Sprintf("included by %s and %s", d.Include, d.Files)
+	case d.Files == nil:
+		return "not matched by any [FILES] mask"
+	case d.Exclude != nil:
+		return fmt.Sprintf("excluded by %s after %s matched", d.Exclude, d.Files)
+	default:
+		return fmt.Sprintf("included by %s", d.Files)
+	}
+}
+
+// Rules returns the masks involved in the decision.
+func (d MaskDecision) Rules() []MaskRule {
+	var rules []MaskRule
+	for _, r := range []*MaskRule{d.Include, d.Files, d.Exclude} {
+		if r != nil {
+			rules = append(rules, *r)
+		}
+	}
+	return rules
+}
+
+// Explain decides for each path, relative to BaseDir, whether a build would pack it.
+// Files found while searching are decided the same way, see OnMaskDecision.
+func (vm *VM) Explain(paths ...string) []MaskDecision {
+	vm.compileMasks()
+	decisions := make([]MaskDecision, len(paths))
+	for i, p := range paths {
+		decisions[i] = vm.decision(p, vm.matchMasks(p))
+	}
+	return decisions
+}
+
+func (vm *VM) decision(relativePath string, m maskMatch) MaskDecision {
+	return MaskDecision{
+		Path:     filepath.ToSlash(relativePath),
+		Included: m.included(),
+		Include:  vm.maskRule("INCLUDE", m.include),
+		Files:    vm.maskRule("FILES", m.files),
+		Exclude:  vm.maskRule("EXCLUDE", m.exclude),
+	}
+}
+
+// section returns the masks of a section with their compiled expressions and lines.
+func (vm *VM) section(name string) ([]string, []*regexp.Regexp, []int) {
+	switch name {
+	case "FILES":
+		return vm.Files, vm.fileMasks, vm.fileLines
+	case "EXCLUDE":
+		return vm.Exclude, vm.excludeMasks, vm.excludeLines
+	default:
+		return vm.Include, vm.includeMasks, vm.includeLines
+	}
+}
+
+// maskRule describes the i-th mask of a section, nil if i is negative.
+func (vm *VM) maskRule(section string, i int) *MaskRule {
+	if i < 0 {
+		return nil
+	}
+	masks, compiled, lines := vm.section(section)
+	r := &MaskRule{Section: section, Index: i, Mask: masks[i], Pattern: compiled[i].String()}
+	// the masks may have been changed after parsing
+	if len(lines) == len(masks) {
+		r.Line = lines[i]
+	}
+	return r
+}
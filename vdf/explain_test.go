@@ -0,0 +1,87 @@
+package vdf
+
+import (
+	"bytes"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	vm, err := parseVM(bytes.NewReader([]byte(`[BEGINVDF]
+VDFName=.\Demo.vdf
+[FILES]
+_Work\Data\* -r
+_Work\*.txt
+[EXCLUDE]
+; thumbnails are never packed
+THUMBS.DB -r
+*.bak -r
+[INCLUDE]
+important.bak -r
+[ENDVDF]
+`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     string
+		included bool
+		rules    []int // lines of the masks involved
+		reason   string
+	}{
+		{"_WORK/DATA/a.tex", true, []int{4}, `included by [FILES] line 4 "` + fixPath(`_Work\Data\* -r`) + `"`},
+		{"_work/readme.txt", true, []int{5}, `included by [FILES] line 5 "` + fixPath(`_Work\*.txt`) + `"`},
+		// a non-recursive "*" does not cross directories or match whitespace
+		{"_work/sub/readme.txt", false, nil, `not matched by any [FILES] mask`},
+		{"_work/read me.txt", false, nil, `not matched by any [FILES] mask`},
+		{"_work/data/sub/thumbs.db", false, []int{4, 8}, `excluded by [EXCLUDE] line 8 "THUMBS.DB -r" after [FILES] line 4 "` + fixPath(`_Work\Data\* -r`) + `" matched`},
+		{"_work/data/important.bak", true, []int{11, 4, 9}, `re-included by [INCLUDE] line 11 "important.bak -r" although excluded by [EXCLUDE] line 9 "*.bak -r", [INCLUDE] overrides all other masks`},
+		{"other/important.bak", true, []int{11}, `included by [INCLUDE] line 11 "important.bak -r" although no [FILES] mask matched, [INCLUDE] overrides all other masks`},
+	}
+	decisions := vm.Explain(func() []string {
+		var paths []string
+		for _, tt := range tests {
+			paths = append(paths, tt.path)
+		}
+		return paths
+	}()...)
+	for i, tt := range tests {
+		d := decisions[i]
+		assertEqualf(t, d.Included, tt.included, "%s: included %v, expected %v", tt.path, d.Included, tt.included)
+		assertEqualf(t, d.Reason(), tt.reason, "%s: %q, expected %q", tt.path, d.Reason(), tt.reason)
+		var lines []int
+		for _, r := range d.Rules() {
+			lines = append(lines, r.Line)
+		}
+		if !slices.Equal(lines, tt.rules) {
+			t.Errorf("%s: rules on lines %v, expected %v", tt.path, lines, tt.rules)
+		}
+	}
+}
+
+func TestOnMaskDecision(t *testing.T) {
+	base := t.TempDir()
+	writeTestFiles(t, base, map[string]string{"a.txt": "a", "b.bak": "b", "sub/c.txt": "c"})
+	var included, excluded []string
+	vm := &VM{
+		BaseDir: base,
+		VDFName: filepath.Join(t.TempDir(), "test.vdf"),
+		Files:   []string{"* -r"},
+		Exclude: []string{"*.bak -r"},
+		OnMaskDecision: func(d MaskDecision) {
+			if d.Included {
+				included = append(included, d.Path)
+			} else {
+				excluded = append(excluded, d.Path)
+			}
+		},
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(included, []string{"a.txt", "sub/c.txt"}) || !slices.Equal(excluded, []string{"b.bak"}) {
+		t.Errorf("unexpected decisions, included %v, excluded %v", included, excluded)
+	}
+}
@@ -0,0 +1,128 @@
+package vdf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimestampLayout is the layout of timestamps in scripts and the default layout on the command line.
+const TimestampLayout = "2006-01-02 15:04:05"
+
+// ParseTimestamp parses a timestamp given in a script or on the command line, one of
+//   - "2006-01-02 15:04:05" or "2006-01-02T15:04:05"
+//   - "2006-01-02", midnight of that day
+//   - RFC 3339, "2006-01-02T15:04:05+01:00"
+//   - unix epoch seconds, "1638102700" or "@1638102700"
+//
+// Values without a zone are interpreted in loc. FAT timestamps store the
+// wall clock, so the result is always converted to loc.
+func ParseTimestamp(s string, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if digits := strings.TrimPrefix(s, "@"); digits != "" && strings.Trim(digits, "0123456789") == "" {
+		sec, err := strconv.ParseInt(digits, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid epoch seconds %q. %w", s, err)
+		}
+		return time.Unix(sec, 0).In(loc), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.In(loc), nil
+	}
+	for _, layout := range []string{TimestampLayout, "2006-01-02T15:04:05", time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q, expected \"YYYY-MM-dd HH:mm:ss\", \"YYYY-MM-dd\", RFC 3339 or unix epoch seconds", s)
+}
+
+// TimestampPolicy decides what happens to timestamps the archive format can not represent.
+type TimestampPolicy int
+
+const (
+	// TimestampClamp stores the closest representable timestamp.
+	TimestampClamp TimestampPolicy = iota
+	// TimestampStrict fails the build instead.
+	TimestampStrict
+)
+
+// ParseTimestampPolicy parses "clamp" or "error".
+func ParseTimestampPolicy(s string) (TimestampPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "clamp":
+		return TimestampClamp, nil
+	case "error", "strict":
+		return TimestampStrict, nil
+	}
+	return 0, fmt.Errorf("unknown timestamp policy %q, expected \"clamp\" or \"error\"", s)
+}
+
+func (p TimestampPolicy) String() string {
+	if p == TimestampStrict {
+		return "error"
+	}
+	return "clamp"
+}
+
+// Range of FAT timestamps, 7 bits of years since 1980 in two second steps.
+var (
+	fatMin = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+	fatMax = time.Date(2107, 12, 31, 23, 59, 58, 0, time.UTC)
+)
+
+// FATTime converts the wall clock of t into an MS-DOS timestamp as used by FormatV2.
+// FAT timestamps have a resolution of two seconds, odd seconds are rounded down.
+// Times before 1980 or after 2107 are clamped or rejected depending on policy.
+func FATTime(t time.Time, policy TimestampPolicy) (uint32, error) {
+	/* https://stackoverflow.com/a/15763512
+
+				   24                16                 8                 0
+	+-+-+-+-+-+-+-+-+ +-+-+-+-+-+-+-+-+ +-+-+-+-+-+-+-+-+ +-+-+-+-+-+-+-+-+
+	|Y|Y|Y|Y|Y|Y|Y|M| |M|M|M|D|D|D|D|D| |h|h|h|h|h|m|m|m| |m|m|m|s|s|s|s|s|
+	+-+-+-+-+-+-+-+-+ +-+-+-+-+-+-+-+-+ +-+-+-+-+-+-+-+-+ +-+-+-+-+-+-+-+-+
+	 \___________/ \_______/ \_______/   \_______/ \___________/ \_______/
+		year        month       day         hour     minute        second
+
+	The year is stored as an offset from 1980.
+	Seconds are stored in two-second increments.
+	(So if the "second" value is 15, it actually represents 30 seconds.)
+	*/
+
+	// FAT timestamps carry no time zone, compare the wall clock only
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	if wall.Before(fatMin) || wall.After(fatMax) {
+		if policy == TimestampStrict {
+			return 0, fmt.Errorf("timestamp %s is outside of the range %s to %s of %s archives",
+				wall.Format(time.DateTime), fatMin.Format(time.DateTime), fatMax.Format(time.DateTime), FormatV2)
+		}
+		if wall.Before(fatMin) {
+			wall = fatMin
+		} else {
+			wall = fatMax
+		}
+	}
+
+	fdt := uint32(wall.Year()-1980) << 25
+	fdt |= uint32(wall.Month()) << 21
+	fdt |= uint32(wall.Day()) << 16
+	fdt |= uint32(wall.Hour()) << 11
+	fdt |= uint32(wall.Minute()) << 5
+	fdt |= uint32(wall.Second()) >> 1
+	return fdt, nil
+}
+
+// ParseFATTime is the inverse of FATTime, the result is in UTC.
+// It fails for values which do not denote a valid date and time.
+func ParseFATTime(fdt uint32) (time.Time, error) {
+	year, month, day := int(fdt>>25)+1980, time.Month(fdt>>21&0x0F), int(fdt>>16&0x1F)
+	hour, minute, second := int(fdt>>11&0x1F), int(fdt>>5&0x3F), int(fdt&0x1F)*2
+
+	// time.Date normalizes out of range values, e.g. month 13, which a valid value survives unchanged
+	t := time.Date(year, month, day, hour, minute, second, 0, time.UTC)
+	if t.Month() != month || t.Day() != day || t.Hour() != hour || t.Minute() != minute || t.Second() != second {
+		return time.Time{}, fmt.Errorf("invalid FAT timestamp 0x%08X", fdt)
+	}
+	return t, nil
+}
@@ -0,0 +1,135 @@
+package vdf
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFATTimeRoundTrip(t *testing.T) {
+	for _, ts := range []time.Time{
+		fatMin,
+		fatMax,
+		time.Date(2021, 11, 28, 12, 31, 40, 0, time.UTC),
+		time.Date(2000, 2, 29, 23, 59, 58, 0, time.UTC),
+	} {
+		fdt, err := FATTime(ts, TimestampStrict)
+		if err != nil {
+			t.Fatalf("%v: %v", ts, err)
+		}
+		got, err := ParseFATTime(fdt)
+		if err != nil {
+			t.Fatalf("%v: %v", ts, err)
+		}
+		assertEqualf(t, got, ts, "round trip of 0x%08X", fdt)
+	}
+}
+
+func TestFATTimeRoundsOddSeconds(t *testing.T) {
+	fdt, err := FATTime(time.Date(2021, 11, 28, 12, 31, 41, 999, time.UTC), TimestampStrict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := ParseFATTime(fdt)
+	assertEqual(t, got, time.Date(2021, 11, 28, 12, 31, 40, 0, time.UTC))
+}
+
+func TestFATTimeOutOfRange(t *testing.T) {
+	tests := []struct {
+		in, want time.Time
+	}{
+		{time.Date(1979, 12, 31, 23, 59, 59, 0, time.UTC), fatMin},
+		{time.Date(1601, 1, 1, 0, 0, 0, 0, time.UTC), fatMin},
+		{time.Date(2108, 1, 1, 0, 0, 0, 0, time.UTC), fatMax},
+		{time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC), fatMax},
+	}
+	for _, tt := range tests {
+		if _, err := FATTime(tt.in, TimestampStrict); err == nil {
+			t.Errorf("%v: expected an error", tt.in)
+		}
+		fdt, err := FATTime(tt.in, TimestampClamp)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.in, err)
+		}
+		got, _ := ParseFATTime(fdt)
+		assertEqualf(t, got, tt.want, "clamping %v", tt.in)
+	}
+}
+
+func TestParseFATTimeRejectsInvalidValues(t *testing.T) {
+	valid, _ := FATTime(time.Date(2021, 11, 28, 12, 31, 40, 0, time.UTC), TimestampStrict)
+	for name, fdt := range map[string]uint32{
+		"month 0":   valid &^ (0x0F << 21),
+		"month 13":  valid&^(0x0F<<21) | 13<<21,
+		"day 0":     valid &^ (0x1F << 16),
+		"hour 24":   valid&^(0x1F<<11) | 24<<11,
+		"minute 60": valid&^(0x3F<<5) | 60<<5,
+		"second 60": valid&^0x1F | 30,
+		"Nov 31":    valid&^(0x1F<<16) | 31<<16,
+	} {
+		if _, err := ParseFATTime(fdt); err == nil {
+			t.Errorf("%s: expected 0x%08X to be rejected", name, fdt)
+		}
+	}
+}
+
+func TestStrictTimestampPolicyFailsBuild(t *testing.T) {
+	base := t.TempDir()
+	writeTestFiles(t, base, map[string]string{"a.txt": "a"})
+	vm := &VM{
+		BaseDir:         base,
+		VDFName:         filepath.Join(t.TempDir(), "test.vdf"),
+		Files:           []string{"*"},
+		Timestamp:       time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+		TimestampPolicy: TimestampStrict,
+	}
+	if err := vm.Execute(); err == nil {
+		t.Errorf("expected an error for a timestamp before 1980")
+	}
+
+	vm.Format = FormatV3
+	if err := vm.Execute(); err != nil {
+		t.Errorf("unexpected error for a V3 archive. %v", err)
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	want := time.Date(2021, 11, 28, 12, 31, 40, 0, time.UTC)
+	for _, s := range []string{
+		"2021-11-28 12:31:40",
+		"2021-11-28T12:31:40",
+		"2021-11-28T12:31:40Z",
+		"2021-11-28T13:31:40+01:00",
+		"1638102700",
+		"@1638102700",
+	} {
+		got, err := ParseTimestamp(s, time.UTC)
+		if err != nil {
+			t.Errorf("%q: %v", s, err)
+		} else if !got.Equal(want) || got.Location() != time.UTC {
+			t.Errorf("%q: expected %v, got %v", s, want, got)
+		}
+	}
+
+	got, err := ParseTimestamp("2021-11-28", time.UTC)
+	if err != nil || !got.Equal(time.Date(2021, 11, 28, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("date only: got %v, %v", got, err)
+	}
+
+	// the wall clock is kept in local mode
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	got, err = ParseTimestamp("2021-11-28 12:31:40", loc)
+	if err != nil || got.Hour() != 12 || !got.Equal(want.Add(-2*time.Hour)) {
+		t.Errorf("local time: got %v, %v", got, err)
+	}
+	got, err = ParseTimestamp("1638102700", loc)
+	if err != nil || got.Hour() != 14 {
+		t.Errorf("epoch in local time: got %v, %v", got, err)
+	}
+
+	for _, s := range []string{"", "yesterday", "2021-13-01", "28.11.2021", "@", "-5"} {
+		if _, err := ParseTimestamp(s, time.UTC); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
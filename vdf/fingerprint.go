@@ -0,0 +1,95 @@
+package vdf
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+)
+
+// Fingerprint returns a hash of everything the archive is built from: the settings
+// of vm that end up in the archive, the files matched by its masks and their content.
+// Building twice with the same fingerprint results in the same archive.
+// A zero Timestamp is left out, content hashes are taken from the HashCache if possible.
+// Volumes and Inputs describe the archive the fingerprint stands for afterwards.
+func (vm *VM) Fingerprint(ctx context.Context) (string, error) {
+	format := vm.Format.orDefault()
+	var timestamp time_t
+	if !vm.Timestamp.IsZero() {
+		var err error
+		if timestamp, err = format.timestamp(vm.Timestamp, vm.TimestampPolicy); err != nil {
+			return "", err
+		}
+	}
+	vm.compileMasks()
+	root := &dirEntry{}
+	nFiles, err := vm.searchFiles(ctx, vm.BaseDir, "", root)
+	if err != nil {
+		return "", err
+	}
+	volumes, err := vm.distribute(root, nFiles, format)
+	if err != nil {
+		return "", err
+	}
+
+	h := getHasher()
+	fmt.Fprintf(h, "format %d\ncomment %q\nname %q\ntimestamp %d\nmax volume size %d\n",
+		format, comment(vm.Comment).String(), vm.VDFName, timestamp, vm.MaxVolumeSize)
+	if err := vm.fingerprintDir(ctx, h, root, ""); err != nil {
+		return "", err
+	}
+	vm.volumes = make([]Volume, len(volumes))
+	for i, v := range volumes {
+		vm.volumes[i] = *v
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (vm *VM) fingerprintDir(ctx context.Context, h hash.Hash, dir *dirEntry, path string) error {
+	for _, d := range dir.Dirs {
+		subPath := filepath.Join(path, d.Name)
+		fmt.Fprintf(h, "dir %q %d\n", filepath.ToSlash(subPath), d.Attr)
+		if err := vm.fingerprintDir(ctx, h, d, subPath); err != nil {
+			return err
+		}
+	}
+	for _, f := range dir.Files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fullPath := filepath.Join(vm.BaseDir, path, f.Name)
+		sum, err := vm.hashSource(ctx, fullPath, f)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "file %q %d %d %s\n", filepath.ToSlash(filepath.Join(path, f.Name)), f.Attr, f.Size, sum)
+	}
+	return nil
+}
+
+// hashSource returns the hash of a source file, from the HashCache if possible.
+func (vm *VM) hashSource(ctx context.Context, fullPath string, file *fileEntry) (string, error) {
+	if vm.HashCache != nil {
+		if sum, ok := vm.HashCache.lookup(fullPath, file.Size, file.ModTime); ok {
+			return sum, nil
+		}
+	}
+	src, err := os.Open(fullPath)
+	if err != nil {
+		return "", &BuildError{Phase: PhaseRead, Path: fullPath, Err: err}
+	}
+	defer src.Close()
+	sum, err := hashFile(contextReader{ctx, src})
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", &BuildError{Phase: PhaseRead, Path: fullPath, Err: err}
+	}
+	if vm.HashCache != nil {
+		vm.HashCache.store(fullPath, file.Size, file.ModTime, sum)
+	}
+	return sum, nil
+}
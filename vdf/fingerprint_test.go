@@ -0,0 +1,52 @@
+package vdf
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFingerprint(t *testing.T) {
+	base := t.TempDir()
+	writeTestFiles(t, base, map[string]string{"a.txt": "a", "sub/b.txt": "b"})
+	vm := &VM{
+		BaseDir:   base,
+		VDFName:   filepath.Join(t.TempDir(), "test.vdf"),
+		Files:     []string{"* -r"},
+		Timestamp: time.Date(2021, 11, 28, 12, 31, 40, 0, time.UTC),
+	}
+	fingerprint := func() string {
+		t.Helper()
+		fp, err := vm.Fingerprint(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fp
+	}
+
+	first := fingerprint()
+	assertEqual(t, fingerprint(), first)
+	// the number of workers does not change the archive
+	vm.Workers = 3
+	assertEqual(t, fingerprint(), first)
+
+	changes := []func(){
+		func() { writeTestFiles(t, base, map[string]string{"sub/b.txt": "c"}) },
+		func() { writeTestFiles(t, base, map[string]string{"sub/c.txt": "c"}) },
+		func() { vm.Exclude = []string{"a.txt -r"} },
+		func() { vm.Comment = "changed" },
+		func() { vm.Timestamp = vm.Timestamp.Add(2 * time.Second) },
+		func() { vm.Format = FormatV3 },
+		func() { vm.MaxVolumeSize = 1 << 20 },
+	}
+	seen := map[string]bool{first: true}
+	for i, change := range changes {
+		change()
+		fp := fingerprint()
+		if seen[fp] {
+			t.Errorf("change %d did not change the fingerprint", i)
+		}
+		seen[fp] = true
+	}
+}
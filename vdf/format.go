@@ -0,0 +1,247 @@
+package vdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// Format selects the on-disk layout of an archive.
+type Format int
+
+const (
+	// FormatV2 is the layout understood by the original games,
+	// with 32 bit sizes, offsets and FAT timestamps.
+	FormatV2 Format = 2
+	// FormatV3 is used by community engine extensions to get past
+	// the 4 GiB limit, with 64 bit sizes, offsets and unix timestamps.
+	FormatV3 Format = 3
+)
+
+// ParseFormat parses a format version like "2", "v3" or "V3.00".
+func ParseFormat(s string) (Format, error) {
+	switch strings.TrimSuffix(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "V"), ".00") {
+	case "2":
+		return FormatV2, nil
+	case "3":
+		return FormatV3, nil
+	}
+	return 0, fmt.Errorf("unknown vdf format %q, expected \"2\" or \"3\"", s)
+}
+
+func (f Format) String() string {
+	return fmt.Sprintf("V%d.00", int(f))
+}
+
+// orDefault returns FormatV2 for the zero value.
+func (f Format) orDefault() Format {
+	if f == 0 {
+		return FormatV2
+	}
+	return f
+}
+
+func (f Format) version() Version {
+	var v Version
+	copy(v[:], "PSVDSC_"+f.String()+"\n\r\n\r")
+	return v
+}
+
+func (f Format) headerSize() uint32 {
+	if f == FormatV3 {
+		return uint32(binary.Size(headerV3{}))
+	}
+	return uint32(binary.Size(headerV2{}))
+}
+
+func (f Format) entrySize() uint32 {
+	if f == FormatV3 {
+		return uint32(binary.Size(entryV3{}))
+	}
+	return uint32(binary.Size(entryV2{}))
+}
+
+// maxSize is the largest size or offset the format can store.
+func (f Format) maxSize() uint64 {
+	if f == FormatV3 {
+		return math.MaxUint64
+	}
+	return math.MaxUint32
+}
+
+// timestamp encodes t, a FAT timestamp for FormatV2 and a unix timestamp for FormatV3.
+func (f Format) timestamp(t time.Time, policy TimestampPolicy) (time_t, error) {
+	if f == FormatV3 {
+		if t.Unix() < 0 {
+			if policy == TimestampStrict {
+				return 0, fmt.Errorf("timestamp %s is before 1970, which %s archives can not store", t.Format(time.DateTime), f)
+			}
+			return 0, nil
+		}
+		return time_t(t.Unix()), nil
+	}
+	fdt, err := FATTime(t, policy)
+	return time_t(fdt), err
+}
+
+func (f Format) time(ts time_t) (time.Time, error) {
+	if f == FormatV3 {
+		return time.Unix(int64(ts), 0).UTC(), nil
+	}
+	return ParseFATTime(uint32(ts))
+}
+
+// StoredTime returns t as it is read back from an archive of format f, in the
+// location of t. It differs from t if t is clamped or loses precision.
+func (f Format) StoredTime(t time.Time, policy TimestampPolicy) (time.Time, error) {
+	f = f.orDefault()
+	ts, err := f.timestamp(t, policy)
+	if err != nil {
+		return time.Time{}, err
+	}
+	stored, err := f.time(ts)
+	if err != nil || f == FormatV3 {
+		return stored.In(t.Location()), err
+	}
+	// FAT timestamps store the wall clock of t
+	return time.Date(stored.Year(), stored.Month(), stored.Day(), stored.Hour(), stored.Minute(), stored.Second(), 0, t.Location()), nil
+}
+
+// Format returns the format of the archive as indicated by its version.
+func (h Header) Format() Format {
+	if f, err := formatOf(h.Version); err == nil {
+		return f
+	}
+	return FormatV2
+}
+
+func formatOf(v Version) (Format, error) {
+	for _, f := range []Format{FormatV2, FormatV3} {
+		if bytes.HasPrefix(v[:], []byte("PSVDSC_"+f.String())) {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: unknown version %q", ErrFormat, v[:])
+}
+
+// on-disk layouts
+
+type headerV2 struct {
+	Comment Comment
+	Version Version
+	Params  struct {
+		EntryCount  uint32
+		FileCount   uint32
+		TimeStamp   uint32
+		DataSize    uint32
+		TableOffset uint32
+		EntrySize   uint32
+	}
+}
+
+type headerV3 struct {
+	Comment Comment
+	Version Version
+	Params  struct {
+		EntryCount  uint32
+		FileCount   uint32
+		TimeStamp   uint64
+		DataSize    uint64
+		TableOffset uint32
+		EntrySize   uint32
+	}
+}
+
+type entryV2 struct {
+	Name    EntryName
+	Offset  uint32
+	Size    uint32
+	Flags   EntryFlag
+	Attribs EntryAttrib
+}
+
+type entryV3 struct {
+	Name    EntryName
+	Offset  uint64
+	Size    uint64
+	Flags   EntryFlag
+	Attribs EntryAttrib
+}
+
+func writeHeader(w io.Writer, f Format, h Header) error {
+	if f == FormatV3 {
+		var d headerV3
+		d.Comment, d.Version = h.Comment, h.Version
+		d.Params.EntryCount, d.Params.FileCount = h.Params.EntryCount, h.Params.FileCount
+		d.Params.TimeStamp, d.Params.DataSize = uint64(h.Params.TimeStamp), uint64(h.Params.DataSize)
+		d.Params.TableOffset, d.Params.EntrySize = h.Params.TableOffset, h.Params.EntrySize
+		return binary.Write(w, binary.LittleEndian, d)
+	}
+	var d headerV2
+	d.Comment, d.Version = h.Comment, h.Version
+	d.Params.EntryCount, d.Params.FileCount = h.Params.EntryCount, h.Params.FileCount
+	d.Params.TimeStamp, d.Params.DataSize = uint32(h.Params.TimeStamp), uint32(h.Params.DataSize)
+	d.Params.TableOffset, d.Params.EntrySize = h.Params.TableOffset, h.Params.EntrySize
+	return binary.Write(w, binary.LittleEndian, d)
+}
+
+func decodeHeader(r io.Reader, f Format) (Header, error) {
+	var h Header
+	if f == FormatV3 {
+		var d headerV3
+		if err := binary.Read(r, binary.LittleEndian, &d); err != nil {
+			return h, err
+		}
+		h.Comment, h.Version = d.Comment, d.Version
+		h.Params = Params{d.Params.EntryCount, d.Params.FileCount, time_t(d.Params.TimeStamp), size_t(d.Params.DataSize), d.Params.TableOffset, d.Params.EntrySize}
+		return h, nil
+	}
+	var d headerV2
+	if err := binary.Read(r, binary.LittleEndian, &d); err != nil {
+		return h, err
+	}
+	h.Comment, h.Version = d.Comment, d.Version
+	h.Params = Params{d.Params.EntryCount, d.Params.FileCount, time_t(d.Params.TimeStamp), size_t(d.Params.DataSize), d.Params.TableOffset, d.Params.EntrySize}
+	return h, nil
+}
+
+func writeTable(w io.Writer, f Format, table vdfsTable) error {
+	if f == FormatV3 {
+		d := make([]entryV3, len(table))
+		for i, e := range table {
+			d[i] = entryV3{e.Name, uint64(e.Offset), uint64(e.Size), e.Flags, e.Attribs}
+		}
+		return binary.Write(w, binary.LittleEndian, d)
+	}
+	d := make([]entryV2, len(table))
+	for i, e := range table {
+		d[i] = entryV2{e.Name, uint32(e.Offset), uint32(e.Size), e.Flags, e.Attribs}
+	}
+	return binary.Write(w, binary.LittleEndian, d)
+}
+
+func decodeTable(r io.Reader, f Format, count int64) ([]EntryMetadata, error) {
+	table := make([]EntryMetadata, count)
+	if f == FormatV3 {
+		d := make([]entryV3, count)
+		if err := binary.Read(r, binary.LittleEndian, d); err != nil {
+			return nil, err
+		}
+		for i, e := range d {
+			table[i] = EntryMetadata{e.Name, size_t(e.Offset), size_t(e.Size), e.Flags, e.Attribs}
+		}
+		return table, nil
+	}
+	d := make([]entryV2, count)
+	if err := binary.Read(r, binary.LittleEndian, d); err != nil {
+		return nil, err
+	}
+	for i, e := range d {
+		table[i] = EntryMetadata{e.Name, size_t(e.Offset), size_t(e.Size), e.Flags, e.Attribs}
+	}
+	return table, nil
+}
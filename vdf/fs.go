@@ -0,0 +1,195 @@
+package vdf
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fsNode mirrors the dirEntry/fileEntry tree of the writer for a
+// Reader, so the archive can be served as an fs.FS.
+type fsNode struct {
+	file     *File // nil for the root directory
+	name     string
+	children []*fsNode
+}
+
+type fsTree struct {
+	once  sync.Once
+	root  *fsNode
+	index map[string]*fsNode
+}
+
+var (
+	_ fs.FS          = (*Reader)(nil)
+	_ fs.ReadDirFS   = (*Reader)(nil)
+	_ fs.StatFS      = (*Reader)(nil)
+	_ fs.ReadDirFile = (*fsDir)(nil)
+	_ io.Seeker      = (*fsFile)(nil)
+)
+
+func (r *Reader) buildTree() {
+	root := &fsNode{name: "."}
+	index := map[string]*fsNode{".": root}
+	nodes := make(map[*File]*fsNode, len(r.Files))
+	for _, f := range r.Files {
+		nodes[f] = &fsNode{file: f, name: f.Name.String()}
+	}
+
+	// Reader rejects duplicate names, so every valid entry is reachable
+	for _, f := range r.Files {
+		n := nodes[f]
+		if !fs.ValidPath(n.name) || strings.Contains(n.name, "/") {
+			// unreachable through fs.FS, e.g. ".." or "A/B"
+			continue
+		}
+		parent := root
+		if f.parent != nil {
+			parent = nodes[f.parent]
+		}
+		index[strings.ToUpper(f.Path)] = n
+		parent.children = append(parent.children, n)
+	}
+
+	var sortChildren func(n *fsNode)
+	sortChildren = func(n *fsNode) {
+		slices.SortFunc(n.children, func(a, b *fsNode) int { return strings.Compare(a.name, b.name) })
+		for _, c := range n.children {
+			sortChildren(c)
+		}
+	}
+	sortChildren(root)
+
+	r.tree.root = root
+	r.tree.index = index
+}
+
+func (r *Reader) lookup(op, name string) (*fsNode, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	r.tree.once.Do(r.buildTree)
+	n, ok := r.tree.index[strings.ToUpper(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return n, nil
+}
+
+// Open opens the named file or directory of the archive.
+// Names are matched case-insensitively, like the game does.
+func (r *Reader) Open(name string) (fs.File, error) {
+	n, err := r.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if n.isDir() {
+		return &fsDir{node: n, r: r}, nil
+	}
+	return &fsFile{SectionReader: n.file.Open(), info: n.info(r)}, nil
+}
+
+// ReadDir reads the named directory and returns its entries sorted by name.
+func (r *Reader) ReadDir(name string) ([]fs.DirEntry, error) {
+	n, err := r.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !n.isDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	entries := make([]fs.DirEntry, len(n.children))
+	for i, c := range n.children {
+		entries[i] = c.info(r)
+	}
+	return entries, nil
+}
+
+// Stat returns a FileInfo describing the named file or directory.
+func (r *Reader) Stat(name string) (fs.FileInfo, error) {
+	n, err := r.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return n.info(r), nil
+}
+
+func (n *fsNode) isDir() bool { return n.file == nil || n.file.IsDir() }
+
+func (n *fsNode) info(r *Reader) fileInfo {
+	return fileInfo{node: n, modTime: r.Header.Time()}
+}
+
+type fileInfo struct {
+	node    *fsNode
+	modTime time.Time
+}
+
+func (fi fileInfo) Name() string { return fi.node.name }
+func (fi fileInfo) Size() int64 {
+	if fi.node.isDir() {
+		return 0
+	}
+	return int64(fi.node.file.Size)
+}
+func (fi fileInfo) Mode() fs.FileMode {
+	if fi.node.isDir() {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+func (fi fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi fileInfo) IsDir() bool        { return fi.node.isDir() }
+
+// Sys returns the underlying *File, or nil for the root directory.
+func (fi fileInfo) Sys() any {
+	if fi.node.file == nil {
+		return nil
+	}
+	return fi.node.file
+}
+
+// fs.DirEntry
+func (fi fileInfo) Type() fs.FileMode          { return fi.Mode().Type() }
+func (fi fileInfo) Info() (fs.FileInfo, error) { return fi, nil }
+func (fi fileInfo) String() string             { return fs.FormatDirEntry(fi) }
+
+type fsFile struct {
+	*io.SectionReader
+	info fileInfo
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *fsFile) Close() error               { return nil }
+
+type fsDir struct {
+	node   *fsNode
+	r      *Reader
+	offset int
+}
+
+func (d *fsDir) Stat() (fs.FileInfo, error) { return d.node.info(d.r), nil }
+func (d *fsDir) Close() error               { return nil }
+func (d *fsDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.node.name, Err: errors.New("is a directory")}
+}
+
+func (d *fsDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	rest := d.node.children[d.offset:]
+	if count > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if count > 0 && count < len(rest) {
+		rest = rest[:count]
+	}
+	entries := make([]fs.DirEntry, len(rest))
+	for i, c := range rest {
+		entries[i] = c.info(d.r)
+	}
+	d.offset += len(rest)
+	return entries, nil
+}
@@ -0,0 +1,87 @@
+package vdf
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReaderImplementsFS(t *testing.T) {
+	archive := buildTestArchive(t, map[string]string{
+		"a.txt":             "a",
+		"_work/data/b.txt":  "b",
+		"_work/data/c.txt":  "a",
+		"_work/other/d.txt": "d",
+	})
+	r, err := Open(archive)
+	if err != nil {
+		t.Fatalf("Failed to open archive. %v", err)
+	}
+	defer r.Close()
+
+	if err := fstest.TestFS(r, "A.TXT", "_WORK/DATA/B.TXT", "_WORK/DATA/C.TXT", "_WORK/OTHER/D.TXT"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReaderFSIsCaseInsensitive(t *testing.T) {
+	archive := buildTestArchive(t, map[string]string{
+		"_work/data/b.txt": "b",
+	})
+	r, err := Open(archive)
+	if err != nil {
+		t.Fatalf("Failed to open archive. %v", err)
+	}
+	defer r.Close()
+
+	content, err := fs.ReadFile(r, "_Work/Data/b.txt")
+	if err != nil {
+		t.Fatalf("Failed to read file. %v", err)
+	}
+	assertEqual(t, string(content), "b")
+
+	fi, err := fs.Stat(r, "_work/data")
+	if err != nil {
+		t.Fatalf("Failed to stat directory. %v", err)
+	}
+	assertEqual(t, fi.IsDir(), true)
+	assertEqual(t, fi.Name(), "DATA")
+
+	matches, err := fs.Glob(r, "_WORK/*/*.TXT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertCount(t, matches, 1)
+}
+
+func TestReaderFSFindsEntriesBeforeTheirDirectory(t *testing.T) {
+	// A/B is stored after its own content
+	data := rawArchive(t, FormatV2, []EntryMetadata{
+		{Name: entryName("A"), Offset: 3, Flags: EntryFlagDirectory},
+		{Name: entryName("X"), Flags: EntryFlagLastEntry},
+		{Name: entryName("Y"), Flags: EntryFlagLastEntry},
+		{Name: entryName("B"), Offset: 2, Flags: EntryFlagDirectory | EntryFlagLastEntry},
+	})
+	r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(r, "A/B/Y"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReaderRejectsDuplicateNames(t *testing.T) {
+	// names are compared like the game does, case-insensitively
+	lower := entryName("A.TXT")
+	copy(lower[:], "a.txt")
+	data := rawArchive(t, FormatV2, []EntryMetadata{
+		{Name: entryName("A.TXT")},
+		{Name: lower, Flags: EntryFlagLastEntry},
+	})
+	if _, err := NewReader(bytes.NewReader(data), int64(len(data))); !errors.Is(err, ErrFormat) {
+		t.Errorf("expected ErrFormat for a duplicate name, got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package vdf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// racyWindow is how old a file has to be before its hash is cached.
+// A file changed again within the resolution of its modification time
+// would otherwise keep the hash of its previous content.
+const racyWindow = 2 * time.Second
+
+// HashCache remembers the SHA-256 of source files by path, size and
+// modification time, so rebuilding an archive does not hash unchanged files again.
+// It is only a hint, deleting the cache file is always safe.
+type HashCache struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]hashCacheEntry
+	used    map[string]hashCacheEntry
+}
+
+type hashCacheEntry struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"`
+	Hash    string `json:"sha256"`
+}
+
+// LoadHashCache reads the cache stored at path. A missing or unreadable
+// cache results in an empty one, which is written to path by Save.
+func LoadHashCache(path string) *HashCache {
+	c := &HashCache{
+		path:    path,
+		entries: make(map[string]hashCacheEntry),
+		used:    make(map[string]hashCacheEntry),
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &c.entries); err != nil {
+			clear(c.entries)
+		}
+	}
+	return c
+}
+
+// DefaultHashCachePath returns the cache of the archive vdfName
+// inside the user's cache directory.
+func DefaultHashCachePath(vdfName string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(vdfName)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return filepath.Join(dir, "vdfsbuilder", "hashes-"+hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// Path returns where the cache is stored.
+func (c *HashCache) Path() string {
+	return c.path
+}
+
+func (c *HashCache) lookup(path string, size int64, modTime time.Time) (string, bool) {
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// hashes stored since the cache was loaded are newer than the loaded ones
+	e, ok := c.used[key]
+	if !ok {
+		e, ok = c.entries[key]
+	}
+	if !ok || e.Size != size || e.ModTime != modTime.UnixNano() {
+		return "", false
+	}
+	c.used[key] = e
+	return e.Hash, true
+}
+
+func (c *HashCache) store(path string, size int64, modTime time.Time, hash string) {
+	key, err := filepath.Abs(path)
+	if err != nil || time.Since(modTime) < racyWindow {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.used[key] = hashCacheEntry{Size: size, ModTime: modTime.UnixNano(), Hash: hash}
+}
+
+// Save writes the hashes of the files used since the cache was loaded,
+// entries of files no longer part of the archive are dropped.
+func (c *HashCache) Save() error {
+	c.mu.Lock()
+	data, err := json.Marshal(c.used)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory. %w", err)
+	}
+	f, err := CreateAtomic(c.path)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	return f.Commit(false)
+}
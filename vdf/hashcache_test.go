@@ -0,0 +1,145 @@
+package vdf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashCacheSkipsUnchangedFiles(t *testing.T) {
+	for _, limit := range []int64{prefetchLimit, 1} {
+		base := t.TempDir()
+		writeTestFiles(t, base, map[string]string{"a.bin": "aaaa", "b.bin": "bbbb"})
+		old := time.Now().Add(-time.Hour)
+		for _, name := range []string{"a.bin", "b.bin"} {
+			if err := os.Chtimes(filepath.Join(base, name), old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+		defer func(limit int64) { prefetchLimit = limit }(prefetchLimit)
+		prefetchLimit = limit
+
+		cachePath := filepath.Join(t.TempDir(), "hashes.json")
+		build := func(cache *HashCache) map[string]size_t {
+			t.Helper()
+			vm := &VM{
+				BaseDir:   base,
+				VDFName:   filepath.Join(t.TempDir(), "test.vdf"),
+				Files:     []string{"* -r"},
+				HashCache: cache,
+			}
+			if err := vm.Execute(); err != nil {
+				t.Fatal(err)
+			}
+			if err := cache.Save(); err != nil {
+				t.Fatal(err)
+			}
+			r, err := Open(vm.VDFName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			offsets := make(map[string]size_t)
+			for _, f := range r.Files {
+				offsets[f.Path] = f.Offset
+			}
+			return offsets
+		}
+		build(LoadHashCache(cachePath))
+
+		// a cached hash is trusted, claiming b.bin has the content of a.bin deduplicates it
+		cache := LoadHashCache(cachePath)
+		a, b := abs(t, base, "a.bin"), abs(t, base, "b.bin")
+		assertCount(t, mapKeys(cache.entries), 2)
+		e := cache.entries[b]
+		e.Hash = cache.entries[a].Hash
+		cache.entries[b] = e
+		offsets := build(cache)
+		assertEqual(t, offsets["A.BIN"], offsets["B.BIN"])
+
+		// a different modification time hashes the file again
+		if err := os.Chtimes(filepath.Join(base, "b.bin"), old, old.Add(time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		offsets = build(LoadHashCache(cachePath))
+		if offsets["A.BIN"] == offsets["B.BIN"] {
+			t.Errorf("b.bin was deduplicated using a stale hash (limit %d)", limit)
+		}
+	}
+}
+
+func TestHashCacheIgnoresBrokenAndRecentEntries(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "cache", "hashes.json")
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cachePath, []byte("{broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cache := LoadHashCache(cachePath)
+	assertCount(t, mapKeys(cache.entries), 0)
+
+	now, old := time.Now(), time.Now().Add(-time.Hour)
+	cache.store(filepath.Join(dir, "recent.bin"), 1, now, "recent")
+	cache.store(filepath.Join(dir, "old.bin"), 1, old, "old")
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	cache = LoadHashCache(cachePath)
+	hash, ok := cache.lookup(filepath.Join(dir, "old.bin"), 1, old)
+	assertEqual(t, ok, true)
+	assertEqual(t, hash, "old")
+	_, ok = cache.lookup(filepath.Join(dir, "old.bin"), 2, old)
+	assertEqual(t, ok, false)
+	_, ok = cache.lookup(filepath.Join(dir, "recent.bin"), 1, now)
+	assertEqual(t, ok, false)
+
+	// entries not used since loading are dropped
+	if err := LoadHashCache(filepath.Join(dir, "missing.json")).Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "missing.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, strings.TrimSpace(string(data)), "{}")
+}
+
+func TestHashCacheFindsStoredHashes(t *testing.T) {
+	dir := t.TempDir()
+	cache := LoadHashCache(filepath.Join(dir, "hashes.json"))
+	old := time.Now().Add(-time.Hour)
+	changed, added := filepath.Join(dir, "changed.bin"), filepath.Join(dir, "added.bin")
+	cache.entries[abs(t, changed)] = hashCacheEntry{Size: 1, ModTime: old.UnixNano(), Hash: "loaded"}
+
+	// no Save and Load in between, the stored hashes replace the loaded ones
+	cache.store(changed, 1, old, "stored")
+	cache.store(added, 2, old, "added")
+	hash, ok := cache.lookup(changed, 1, old)
+	assertEqual(t, ok, true)
+	assertEqual(t, hash, "stored")
+	hash, ok = cache.lookup(added, 2, old)
+	assertEqual(t, ok, true)
+	assertEqual(t, hash, "added")
+}
+
+func abs(t *testing.T, elem ...string) string {
+	t.Helper()
+	p, err := filepath.Abs(filepath.Join(elem...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func mapKeys[K comparable, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,200 @@
+package vdf
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// LintLevel is the severity of a LintFinding.
+type LintLevel int
+
+const (
+	// LintWarning marks masks without any effect.
+	LintWarning LintLevel = iota
+	// LintNote marks masks overlapping with others, which is often intended.
+	LintNote
+)
+
+func (l LintLevel) String() string {
+	if l == LintNote {
+		return "note"
+	}
+	return "warning"
+}
+
+// LintFinding is a problem of a single mask.
+type LintFinding struct {
+	Rule    MaskRule
+	Level   LintLevel
+	Message string
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%s: %s %s", f.Level, f.Rule, f.Message)
+}
+
+// Lint evaluates the masks of vm against the files below BaseDir and reports
+// masks that match nothing, excludes that never exclude a packed file, includes
+// shadowed by [FILES], masks made redundant by others and overlapping masks.
+// Findings are sorted by section and line.
+func (vm *VM) Lint(ctx context.Context) ([]LintFinding, error) {
+	vm.compileMasks()
+	var paths []string
+	err := filepath.WalkDir(vm.BaseDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return &BuildError{Phase: PhaseSearch, Path: path, Err: err}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			rel, err := filepath.Rel(vm.BaseDir, path)
+			if err != nil {
+				return err
+			}
+			paths = append(paths, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	match := func(section string) [][]bool {
+		_, compiled, _ := vm.section(section)
+		m := make([][]bool, len(compiled))
+		for i, rx := range compiled {
+			m[i] = make([]bool, len(paths))
+			for j, p := range paths {
+				m[i][j] = rx.MatchString(p)
+			}
+		}
+		return m
+	}
+	// whether any mask of a section matches each file
+	matchesAny := func(m [][]bool) []bool {
+		result := make([]bool, len(paths))
+		for j := range paths {
+			result[j] = slices.ContainsFunc(m, func(matches []bool) bool { return matches[j] })
+		}
+		return result
+	}
+	files, exclude, include := match("FILES"), match("EXCLUDE"), match("INCLUDE")
+	inFiles, inExclude, inInclude := matchesAny(files), matchesAny(exclude), matchesAny(include)
+
+	// the files each mask decides, the same decision as matchesMasks
+	effective := func(m [][]bool, affects func(j int) bool) [][]bool {
+		eff := make([][]bool, len(m))
+		for i := range m {
+			eff[i] = make([]bool, len(paths))
+			for j := range paths {
+				eff[i][j] = m[i][j] && affects(j)
+			}
+		}
+		return eff
+	}
+	excludeEff := effective(exclude, func(j int) bool { return inFiles[j] && !inInclude[j] })
+	includeEff := effective(include, func(j int) bool { return !inFiles[j] || inExclude[j] })
+
+	var findings []LintFinding
+	findings = append(findings, vm.lintSection("FILES", files, files, func(int) string { return "" })...)
+	findings = append(findings, vm.lintSection("EXCLUDE", exclude, excludeEff, func(i int) string {
+		for j := range paths {
+			if exclude[i][j] && inFiles[j] {
+				return "never excludes anything, every file it matches is re-included by [INCLUDE]"
+			}
+		}
+		return "never excludes anything, no file it matches is included by [FILES]"
+	})...)
+	findings = append(findings, vm.lintSection("INCLUDE", include, includeEff, func(int) string {
+		return "is shadowed, every file it matches is packed through [FILES] anyway"
+	})...)
+	return findings, nil
+}
+
+// lintSection reports the masks of a section that match nothing, have no effect,
+// are covered by the other masks or overlap with an earlier one.
+func (vm *VM) lintSection(section string, matches, effective [][]bool, ineffective func(i int) string) []LintFinding {
+	var findings []LintFinding
+	report := func(i int, level LintLevel, format string, args ...any) {
+		findings = append(findings, LintFinding{Rule: *vm.maskRule(section, i), Level: level, Message: fmt.Sprintf(format, args...)})
+	}
+
+	dead := make([]bool, len(matches))
+	for i := range matches {
+		switch {
+		case !slices.Contains(matches[i], true):
+			report(i, LintWarning, "matches no file below BaseDir")
+			dead[i] = true
+		case !slices.Contains(effective[i], true):
+			report(i, LintWarning, "%s", ineffective(i))
+			dead[i] = true
+		}
+	}
+
+	// from the last mask on, so the later of two equal masks is reported
+	redundant := make([]bool, len(matches))
+	kept := func(k int) bool { return !dead[k] && !redundant[k] }
+	coveredByOthers := func(i, f int) bool {
+		for k := range effective {
+			if k != i && kept(k) && effective[k][f] {
+				return true
+			}
+		}
+		return false
+	}
+	for i := len(matches) - 1; i >= 0; i-- {
+		if dead[i] {
+			continue
+		}
+		redundant[i] = true
+		for f, v := range effective[i] {
+			if v && !coveredByOthers(i, f) {
+				redundant[i] = false
+				break
+			}
+		}
+	}
+	for i := range matches {
+		if !redundant[i] {
+			continue
+		}
+		// the masks kept in the end cover everything the redundant ones did
+		var names []string
+		for k := range effective {
+			if kept(k) && overlap(effective[i], effective[k]) > 0 {
+				names = append(names, vm.maskRule(section, k).String())
+			}
+		}
+		report(i, LintWarning, "is redundant, every file it affects is affected by %s as well", strings.Join(names, ", "))
+	}
+
+	for i := range matches {
+		for k := 0; k < i; k++ {
+			if !kept(i) || !kept(k) {
+				continue
+			}
+			if n := overlap(effective[i], effective[k]); n > 0 {
+				report(i, LintNote, "overlaps with %s on %d of its %d files", vm.maskRule(section, k), n, overlap(effective[i], effective[i]))
+			}
+		}
+	}
+
+	slices.SortStableFunc(findings, func(a, b LintFinding) int { return a.Rule.Index - b.Rule.Index })
+	return findings
+}
+
+// overlap counts the files in both sets.
+func overlap(a, b []bool) int {
+	n := 0
+	for f := range a {
+		if a[f] && b[f] {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,75 @@
+package vdf
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	base := t.TempDir()
+	writeTestFiles(t, base, map[string]string{
+		"_work/data/a.tex":      "a",
+		"_work/data/b.tex":      "b",
+		"_work/data/Thumbs.db":  "c",
+		"_work/data/keep.bak":   "d",
+		"_work/data/other.bak":  "e",
+		"_work/scripts/c.d":     "f",
+		"_work/scripts/c.d.bak": "g",
+	})
+	vm, err := parseVM(bytes.NewReader([]byte(`[BEGINVDF]
+[FILES]
+_work\data\* -r
+_work\* -r
+_work\data\*.tex -r
+_work\sounds\* -r
+*.tex -r
+[EXCLUDE]
+THUMBS.DB -r
+DESKTOP.INI -r
+keep.bak -r
+*.bak -r
+_work\scripts\* -r
+[INCLUDE]
+keep.bak -r
+_work\data\a.tex
+[ENDVDF]
+`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm.BaseDir = base
+
+	findings, err := vm.Lint(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		line    int
+		level   LintLevel
+		message string
+	}{
+		{3, LintWarning, "is redundant"},
+		{5, LintWarning, "is redundant"},
+		{6, LintWarning, "matches no file below BaseDir"},
+		{7, LintWarning, "is redundant"},
+		{10, LintWarning, "matches no file below BaseDir"},
+		{11, LintWarning, "never excludes anything, every file it matches is re-included by [INCLUDE]"},
+		{13, LintNote, `overlaps with [EXCLUDE] line 12 "*.bak -r" on 1 of its 2 files`},
+		{16, LintWarning, "is shadowed"},
+	}
+	var got []string
+	for _, f := range findings {
+		got = append(got, f.String())
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("expected %d findings, got\n%s", len(want), strings.Join(got, "\n"))
+	}
+	for i, w := range want {
+		f := findings[i]
+		if f.Rule.Line != w.line || f.Level != w.level || !strings.HasPrefix(f.Message, w.message) {
+			t.Errorf("expected line %d %s %q, got %s", w.line, w.level, w.message, got[i])
+		}
+	}
+}
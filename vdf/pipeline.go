@@ -0,0 +1,230 @@
+package vdf
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// prefetchLimit is the largest file read into memory by the workers,
+// larger files are streamed into the archive by the writer.
+var prefetchLimit int64 = 8 << 20
+
+// readAheadPerWorker is the memory for prefetched files per worker, in
+// multiples of prefetchLimit so every prefetched file fits.
+const readAheadPerWorker = 2
+
+// dataJob is a file whose data is appended to the archive.
+type dataJob struct {
+	index    uint // into the table
+	fullPath string
+	file     *fileEntry
+}
+
+// prefetched is the content and hash of a file read by a worker.
+// stream is set for files too large to be held in memory, their hash
+// is only known if it was cached.
+type prefetched struct {
+	data   []byte
+	hash   string
+	stream bool
+	err    error
+}
+
+func (vm *VM) workers() int {
+	if vm.Workers > 0 {
+		return vm.Workers
+	}
+	return runtime.NumCPU()
+}
+
+// writeData appends the data of jobs to f in their order. Workers read and hash
+// the files ahead of the writer, which keeps the archive identical to one written
+// file by file. Duplicates point to the data of their first occurrence.
+func (vm *VM) writeData(ctx context.Context, f *os.File, table vdfsTable, jobs []dataJob, dataPos *size_t, readErrs *[]error) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	// stops the workers once the writer is done, ctx is still used for reading
+	stop, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := vm.workers()
+	// bounds the memory held by prefetched files
+	ahead := newByteBudget(int64(workers) * readAheadPerWorker * prefetchLimit)
+	results := make([]chan prefetched, len(jobs))
+	for i := range results {
+		results[i] = make(chan prefetched, 1)
+	}
+	pending := make(chan int)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(pending)
+		for i := range jobs {
+			if !ahead.acquire(stop, prefetchSize(jobs[i])) {
+				return
+			}
+			select {
+			case pending <- i:
+			case <-stop.Done():
+				return
+			}
+		}
+	}()
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range pending {
+				results[i] <- vm.prefetch(ctx, jobs[i])
+			}
+		}()
+	}
+
+	for i, job := range jobs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var r prefetched
+		select {
+		case r = <-results[i]:
+			ahead.release(prefetchSize(job))
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		hash, err := r.hash, r.err
+		_, known := vm.fileHashToDataOffset[hash]
+		switch {
+		case err != nil, known:
+			// nothing to write for unreadable files and duplicates
+		case r.stream:
+			// copy first and roll back if the data turns out to be a duplicate,
+			// so every file is read only once
+			hash, err = vm.appendDataFromDisk(ctx, f, job.fullPath, job.file, r.hash)
+		default:
+			if _, err := f.Write(r.data); err != nil {
+				return &BuildError{Phase: PhaseWrite, Path: job.fullPath, Err: err}
+			}
+		}
+
+		if err != nil {
+			var be *BuildError
+			if ctx.Err() != nil || !errors.As(err, &be) || be.Phase != PhaseRead {
+				return err
+			}
+			*readErrs = append(*readErrs, err)
+		} else if pos, ok := vm.fileHashToDataOffset[hash]; !ok {
+			vm.fileHashToDataOffset[hash] = int64(*dataPos)
+			table[job.index].Offset = *dataPos
+			*dataPos += size_t(job.file.Size)
+			continue
+		} else {
+			table[job.index].Offset = size_t(pos)
+		}
+		if _, err := f.Seek(int64(*dataPos), io.SeekStart); err != nil {
+			return &BuildError{Phase: PhaseWrite, Path: job.fullPath, Err: fmt.Errorf("failed to roll back. %w", err)}
+		}
+	}
+	return nil
+}
+
+// prefetchSize is the memory prefetch holds for the data of job.
+func prefetchSize(job dataJob) int64 {
+	if job.file.Size > prefetchLimit {
+		return 0
+	}
+	return job.file.Size
+}
+
+// byteBudget limits the bytes held at the same time. It is acquired by a single
+// goroutine and released in the same order.
+type byteBudget struct {
+	mu   sync.Mutex
+	free int64
+	// closed and replaced on every release
+	released chan struct{}
+}
+
+func newByteBudget(size int64) *byteBudget {
+	return &byteBudget{free: size, released: make(chan struct{})}
+}
+
+// acquire waits until n bytes are free, it returns false if ctx is done first.
+func (b *byteBudget) acquire(ctx context.Context, n int64) bool {
+	for {
+		b.mu.Lock()
+		if b.free >= n {
+			b.free -= n
+			b.mu.Unlock()
+			return true
+		}
+		released := b.released
+		b.mu.Unlock()
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+func (b *byteBudget) release(n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.free += n
+	close(b.released)
+	b.released = make(chan struct{})
+}
+
+// prefetch reads and hashes a file small enough to be kept in memory,
+// larger files are only looked up in the HashCache.
+func (vm *VM) prefetch(ctx context.Context, job dataJob) prefetched {
+	var cached string
+	if vm.HashCache != nil {
+		cached, _ = vm.HashCache.lookup(job.fullPath, job.file.Size, job.file.ModTime)
+	}
+	if job.file.Size > prefetchLimit {
+		return prefetched{hash: cached, stream: true}
+	}
+	if err := ctx.Err(); err != nil {
+		return prefetched{err: err}
+	}
+	src, err := os.Open(job.fullPath)
+	if err != nil {
+		return prefetched{err: &BuildError{Phase: PhaseRead, Path: job.fullPath, Err: err}}
+	}
+	defer src.Close()
+
+	data := bytes.NewBuffer(make([]byte, 0, job.file.Size))
+	var dst io.Writer = data
+	hasher := getHasher()
+	if cached == "" {
+		dst = io.MultiWriter(data, hasher)
+	}
+	n, err := io.Copy(dst, contextReader{ctx, src})
+	if err != nil {
+		if ctx.Err() != nil {
+			return prefetched{err: ctx.Err()}
+		}
+		return prefetched{err: &BuildError{Phase: PhaseRead, Path: job.fullPath, Err: err}}
+	}
+	if n != job.file.Size {
+		return prefetched{err: &BuildError{Phase: PhaseRead, Path: job.fullPath, Err: fmt.Errorf("size changed from %d to %d bytes during the build", job.file.Size, n)}}
+	}
+	if cached != "" {
+		return prefetched{data: data.Bytes(), hash: cached}
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	if vm.HashCache != nil {
+		vm.HashCache.store(job.fullPath, job.file.Size, job.file.ModTime, hash)
+	}
+	return prefetched{data: data.Bytes(), hash: hash}
+}
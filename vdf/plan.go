@@ -0,0 +1,77 @@
+package vdf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+)
+
+// PlannedVolume is a volume as ExecuteContext would write it.
+type PlannedVolume struct {
+	Volume
+	// Archive lists the header and entries of the archive as they would be
+	// written. The content of its files can not be opened.
+	Archive *Reader
+}
+
+// Plan searches the files of vm and lays out the archives without writing
+// anything. Files are hashed to find duplicates, using the HashCache if set.
+func (vm *VM) Plan(ctx context.Context) ([]PlannedVolume, error) {
+	format, timestamp, volumes, err := vm.layoutVolumes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	planned := make([]PlannedVolume, len(volumes))
+	for i, v := range volumes {
+		header := vm.volumeHeader(v, format, timestamp)
+		tbl := make(vdfsTable, header.Params.EntryCount)
+		dataPos := size_t(header.Params.TableOffset) + size_t(header.Params.EntryCount)*size_t(header.Params.EntrySize)
+
+		startIndex := uint(0)
+		var jobs []dataJob
+		vm.readFilesFromList(v.root, tbl, vm.BaseDir, "", &startIndex, &jobs)
+
+		// same layout as writeData, duplicates point to their first occurrence
+		offsets := make(map[string]size_t)
+		var readErrs []error
+		for _, job := range jobs {
+			hash, err := vm.hashSource(ctx, job.fullPath, job.file)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, err
+				}
+				readErrs = append(readErrs, err)
+				continue
+			}
+			if pos, ok := offsets[hash]; ok {
+				tbl[job.index].Offset = pos
+				continue
+			}
+			offsets[hash] = dataPos
+			tbl[job.index].Offset = dataPos
+			dataPos += size_t(job.file.Size)
+		}
+		if len(readErrs) != 0 {
+			return nil, errors.Join(readErrs...)
+		}
+
+		table := make([]EntryMetadata, len(tbl))
+		for j, e := range tbl {
+			table[j] = e.EntryMetadata
+		}
+		r := &Reader{
+			Header: header,
+			Files:  make([]*File, len(table)),
+			r:      bytes.NewReader(nil),
+			size:   int64(dataPos),
+		}
+		if len(table) != 0 {
+			if err := r.readDir(table, make([]bool, len(table))); err != nil {
+				return nil, err
+			}
+		}
+		planned[i] = PlannedVolume{Volume: *v, Archive: r}
+	}
+	return planned, nil
+}
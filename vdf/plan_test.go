@@ -0,0 +1,51 @@
+package vdf
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPlanMatchesArchive(t *testing.T) {
+	base := t.TempDir()
+	writeTestFiles(t, base, map[string]string{
+		"a.txt":     "same",
+		"sub/b.txt": "same",
+		"sub/c.txt": "other",
+	})
+	vm := &VM{
+		BaseDir:   base,
+		VDFName:   filepath.Join(t.TempDir(), "test.vdf"),
+		Files:     []string{"* -r"},
+		Timestamp: time.Date(2021, 11, 28, 12, 31, 40, 0, time.UTC),
+	}
+	planned, err := vm.Plan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(vm.VDFName); !os.IsNotExist(err) {
+		t.Fatalf("expected no archive to be written, got %v", err)
+	}
+	assertCount(t, planned, 1)
+
+	if err := vm.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := Open(vm.VDFName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	plan := planned[0].Archive
+	assertEqual(t, planned[0].Name, vm.VDFName)
+	assertEqual(t, plan.Header, r.Header)
+	assertEqual(t, plan.Size(), r.Size())
+	assertCount(t, plan.Files, len(r.Files))
+	for i, f := range r.Files {
+		assertEqual(t, plan.Files[i].Path, f.Path)
+		assertEqual(t, plan.Files[i].EntryMetadata, f.EntryMetadata)
+	}
+}
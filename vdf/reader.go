@@ -0,0 +1,241 @@
+package vdf
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ErrFormat is returned when the data is not a valid VDF archive.
+var ErrFormat = errors.New("vdf: not a valid archive")
+
+// Reader provides read access to the entries of a VDF archive.
+type Reader struct {
+	Header Header
+	// Files contains every entry of the archive in table order,
+	// directories included.
+	Files []*File
+
+	r      io.ReaderAt
+	size   int64
+	closer io.Closer
+	tree   fsTree
+}
+
+// File is a single entry of the archive table.
+type File struct {
+	EntryMetadata
+
+	// Path is the slash separated path of the entry within the archive.
+	Path string
+	// Index is the position of the entry within the table.
+	Index int
+
+	r      io.ReaderAt
+	parent *File // nil for entries of the root directory
+}
+
+// IsDir reports whether the entry describes a directory.
+func (f *File) IsDir() bool { return f.Flags&EntryFlagDirectory != 0 }
+
+// Open returns a reader for the contents of the entry.
+// For directories the returned reader is empty.
+func (f *File) Open() *io.SectionReader {
+	if f.IsDir() {
+		return io.NewSectionReader(f.r, 0, 0)
+	}
+	return io.NewSectionReader(f.r, int64(f.Offset), int64(f.Size))
+}
+
+// Open opens the VDF archive at path.
+// The returned Reader must be closed once it is no longer used.
+func Open(path string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r, err := NewReader(f, fi.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.closer = f
+	return r, nil
+}
+
+// NewReader returns a Reader for the archive in r of the given size.
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	header, err := readHeader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	if want := header.Format().entrySize(); header.Params.EntrySize != want {
+		return nil, fmt.Errorf("%w: entry size %d, expected %d", ErrFormat, header.Params.EntrySize, want)
+	}
+	table, err := readTable(r, size, header)
+	if err != nil {
+		return nil, err
+	}
+
+	vr := &Reader{
+		Header: header,
+		Files:  make([]*File, len(table)),
+		r:      r,
+		size:   size,
+	}
+	if len(table) == 0 {
+		return vr, nil
+	}
+	visited := make([]bool, len(table))
+	if err := vr.readDir(table, visited); err != nil {
+		return nil, err
+	}
+	for i, v := range vr.Files {
+		if v == nil {
+			return nil, fmt.Errorf("%w: entry %d is not reachable from the root", ErrFormat, i)
+		}
+	}
+	return vr, nil
+}
+
+// Close closes the underlying file if the Reader was created by Open.
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// Size returns the size of the archive in bytes.
+func (r *Reader) Size() int64 { return r.size }
+
+// StoredSize returns the number of bytes the file contents take up in the
+// archive. Data shared by deduplicated files is counted once, unlike in
+// Header.Params.DataSize.
+func (r *Reader) StoredSize() int64 {
+	type span struct{ start, end int64 }
+	var spans []span
+	for _, f := range r.Files {
+		if !f.IsDir() && f.Size > 0 {
+			spans = append(spans, span{int64(f.Offset), int64(f.Offset) + int64(f.Size)})
+		}
+	}
+	slices.SortFunc(spans, func(a, b span) int { return cmp.Compare(a.start, b.start) })
+
+	var size, end int64
+	for _, s := range spans {
+		if s.start < end {
+			s.start = end
+		}
+		if s.end > s.start {
+			size += s.end - s.start
+			end = s.end
+		}
+	}
+	return size
+}
+
+// readDir creates the Files of the directory tree starting at the root. The
+// tree is walked without recursion, a malicious table could otherwise nest
+// deep enough to exhaust the stack.
+func (r *Reader) readDir(table []EntryMetadata, visited []bool) error {
+	type dir struct {
+		start uint64
+		path  string
+		file  *File
+	}
+	pending := []dir{{start: 0}}
+	for len(pending) > 0 {
+		d := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		// the game matches names case-insensitively
+		names := make(map[string]bool)
+		for i := d.start; ; i++ {
+			if i >= uint64(len(table)) {
+				return fmt.Errorf("%w: directory %q is not terminated", ErrFormat, d.path)
+			}
+			if visited[i] {
+				return fmt.Errorf("%w: entry %d is referenced more than once", ErrFormat, i)
+			}
+			visited[i] = true
+
+			e := table[i]
+			p := e.Name.String()
+			if d.path != "" {
+				p = d.path + "/" + p
+			}
+			key := strings.ToUpper(e.Name.String())
+			if names[key] {
+				return fmt.Errorf("%w: %q exists more than once", ErrFormat, p)
+			}
+			names[key] = true
+			f := &File{
+				EntryMetadata: e,
+				Path:          p,
+				Index:         int(i),
+				r:             r.r,
+				parent:        d.file,
+			}
+			r.Files[i] = f
+
+			if f.IsDir() {
+				pending = append(pending, dir{start: uint64(e.Offset), path: p, file: f})
+			} else if exceedsSize(e, r.size) {
+				return fmt.Errorf("%w: data of %q exceeds the archive", ErrFormat, p)
+			}
+
+			if e.Flags&EntryFlagLastEntry != 0 {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// exceedsSize reports whether the data of e does not fit into size bytes,
+// without overflowing for offsets and sizes of 2^63 and above.
+func exceedsSize(e EntryMetadata, size int64) bool {
+	return uint64(e.Size) > uint64(size) || uint64(e.Offset) > uint64(size)-uint64(e.Size)
+}
+
+// readHeader reads the header of either format, as indicated by its version.
+func readHeader(r io.ReaderAt, size int64) (Header, error) {
+	var version Version
+	if _, err := r.ReadAt(version[:], int64(len(Comment{}))); err != nil {
+		return Header{}, fmt.Errorf("%w: file too small", ErrFormat)
+	}
+	format, err := formatOf(version)
+	if err != nil {
+		return Header{}, err
+	}
+	if size < int64(format.headerSize()) {
+		return Header{}, fmt.Errorf("%w: file too small", ErrFormat)
+	}
+	header, err := decodeHeader(io.NewSectionReader(r, 0, size), format)
+	if err != nil {
+		return header, fmt.Errorf("failed to read header. %w", err)
+	}
+	return header, nil
+}
+
+func readTable(r io.ReaderAt, size int64, header Header) ([]EntryMetadata, error) {
+	count := int64(header.Params.EntryCount)
+	start := int64(header.Params.TableOffset)
+	if start+count*int64(header.Params.EntrySize) > size {
+		return nil, fmt.Errorf("%w: entry table exceeds the archive", ErrFormat)
+	}
+	table, err := decodeTable(io.NewSectionReader(r, start, size-start), header.Format(), count)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read entry table. %w", err)
+	}
+	return table, nil
+}
@@ -0,0 +1,242 @@
+package vdf
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReaderReadsBackWrittenArchive(t *testing.T) {
+	archive := buildTestArchive(t, map[string]string{
+		"README.md":              "readme",
+		"_work/data/a.txt":       "content a",
+		"_work/data/b.txt":       "content b",
+		"_work/data/copy_of_a":   "content a",
+		"_work/scripts/x.d":      "func void x() {};",
+		"_work/scripts/empty.d":  "",
+		"_work/scripts/sub/y.d":  "func void y() {};",
+		"_work/textures/tex.tga": "tga",
+	})
+
+	r, err := Open(archive)
+	if err != nil {
+		t.Fatalf("Failed to open archive. %v", err)
+	}
+	defer r.Close()
+
+	assertEqual(t, int(r.Header.Params.EntryCount), len(r.Files))
+	assertEqual(t, r.Header.Params.FileCount, 8)
+	assertEqual(t, r.Header.Comment.String(), "test archive")
+	assertEqual(t, r.Header.Version.String(), "PSVDSC_V2.00")
+	assertEqual(t, r.Header.Time(), time.Date(2021, 11, 28, 12, 31, 40, 0, time.UTC))
+
+	want := map[string]string{
+		"README.MD":              "readme",
+		"_WORK/DATA/A.TXT":       "content a",
+		"_WORK/DATA/B.TXT":       "content b",
+		"_WORK/DATA/COPY_OF_A":   "content a",
+		"_WORK/SCRIPTS/X.D":      "func void x() {};",
+		"_WORK/SCRIPTS/EMPTY.D":  "",
+		"_WORK/SCRIPTS/SUB/Y.D":  "func void y() {};",
+		"_WORK/TEXTURES/TEX.TGA": "tga",
+	}
+	files := 0
+	for i, f := range r.Files {
+		assertEqual(t, f.Index, i)
+		if f.IsDir() {
+			continue
+		}
+		files++
+		content, err := io.ReadAll(f.Open())
+		if err != nil {
+			t.Fatalf("Failed to read %q. %v", f.Path, err)
+		}
+		expected, ok := want[f.Path]
+		if !ok {
+			t.Errorf("unexpected entry %q", f.Path)
+			continue
+		}
+		assertEqualf(t, string(content), expected, "content of %q differs", f.Path)
+	}
+	assertEqual(t, files, len(want))
+}
+
+func TestReaderStoredSizeCountsDuplicatesOnce(t *testing.T) {
+	archive := buildTestArchive(t, map[string]string{
+		"a.txt":      "content a",
+		"copy_of_a":  "content a",
+		"sub/b.txt":  "content bb",
+		"sub/a.txt":  "content a",
+		"empty.txt":  "",
+		"sub/c.data": "c",
+	})
+	r, err := Open(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	assertEqual(t, r.Header.Params.DataSize, size_t(3*len("content a")+len("content bb")+len("c")))
+	assertEqual(t, r.StoredSize(), int64(len("content a")+len("content bb")+len("c")))
+}
+
+func TestReaderReadsV3Archives(t *testing.T) {
+	base := t.TempDir()
+	writeTestFiles(t, base, map[string]string{
+		"a.txt":            "a",
+		"_work/data/b.txt": "b",
+	})
+	ts := time.Date(2045, 3, 4, 5, 6, 7, 0, time.UTC)
+	vm := &VM{
+		BaseDir:   base,
+		VDFName:   filepath.Join(t.TempDir(), "test.vdf"),
+		Timestamp: ts,
+		Format:    FormatV3,
+		Files:     []string{"* -r"},
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(vm.VDFName)
+	if err != nil {
+		t.Fatalf("Failed to open archive. %v", err)
+	}
+	defer r.Close()
+
+	assertEqual(t, r.Header.Format(), FormatV3)
+	assertEqual(t, r.Header.Version.String(), "PSVDSC_V3.00")
+	assertEqual(t, r.Header.Params.TableOffset, FormatV3.headerSize())
+	assertEqual(t, r.Header.Params.EntrySize, FormatV3.entrySize())
+	assertEqual(t, r.Header.Time(), ts)
+
+	content, err := fs.ReadFile(r, "_WORK/DATA/B.TXT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(content), "b")
+
+	problems, err := VerifyFile(vm.VDFName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertCount(t, problems, 0)
+}
+
+func TestParseFormat(t *testing.T) {
+	for in, want := range map[string]Format{"2": FormatV2, "v2": FormatV2, "V3.00": FormatV3, " 3 ": FormatV3} {
+		got, err := ParseFormat(in)
+		if err != nil {
+			t.Errorf("ParseFormat(%q) failed. %v", in, err)
+		}
+		assertEqual(t, got, want)
+	}
+	if _, err := ParseFormat("4"); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
+
+func TestReaderRejectsGarbage(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "garbage.vdf")
+	if err := os.WriteFile(p, make([]byte, 1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(p); err == nil {
+		t.Errorf("expected an error for an invalid archive")
+	}
+}
+
+func TestReaderRejectsOverflowingV3Entries(t *testing.T) {
+	data := rawArchive(t, FormatV3, []EntryMetadata{
+		{Name: entryName("A.TXT"), Offset: 1 << 63, Size: 1 << 63, Flags: EntryFlagLastEntry},
+	})
+	if _, err := NewReader(bytes.NewReader(data), int64(len(data))); !errors.Is(err, ErrFormat) {
+		t.Errorf("expected ErrFormat for data beyond the archive, got %v", err)
+	}
+}
+
+func TestReaderOpensDeeplyNestedDirectories(t *testing.T) {
+	const depth = 2000
+	table := make([]EntryMetadata, depth+1)
+	for i := range depth {
+		table[i] = EntryMetadata{Name: entryName("D"), Offset: size_t(i + 1), Flags: EntryFlagDirectory | EntryFlagLastEntry}
+	}
+	table[depth] = EntryMetadata{Name: entryName("F"), Flags: EntryFlagLastEntry}
+	data := rawArchive(t, FormatV2, table)
+
+	r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, r.Files[depth].Path, strings.Repeat("D/", depth)+"F")
+}
+
+// rawArchive encodes a header and the given table without any data,
+// to create archives the writer would never produce.
+func rawArchive(t testing.TB, format Format, table []EntryMetadata) []byte {
+	t.Helper()
+	files := 0
+	tbl := make(vdfsTable, len(table))
+	for i, e := range table {
+		tbl[i].EntryMetadata = e
+		if e.Flags&EntryFlagDirectory == 0 {
+			files++
+		}
+	}
+	header := Header{
+		Version: format.version(),
+		Params: Params{
+			EntryCount:  uint32(len(table)),
+			FileCount:   uint32(files),
+			TableOffset: format.headerSize(),
+			EntrySize:   format.entrySize(),
+		},
+	}
+	var b bytes.Buffer
+	if err := writeHeader(&b, format, header); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeTable(&b, format, tbl); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// buildTestArchive writes files into a temporary directory and packs
+// them into an archive, returning the path of the archive.
+func buildTestArchive(t testing.TB, files map[string]string) string {
+	t.Helper()
+	base := t.TempDir()
+	writeTestFiles(t, base, files)
+
+	vm := &VM{
+		Comment:   "test archive",
+		BaseDir:   base,
+		VDFName:   filepath.Join(t.TempDir(), "test.vdf"),
+		Timestamp: time.Date(2021, 11, 28, 12, 31, 40, 0, time.UTC),
+		Files:     []string{"* -r"},
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("Failed to build archive. %v", err)
+	}
+	return vm.VDFName
+}
+
+func writeTestFiles(t testing.TB, base string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(base, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
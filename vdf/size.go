@@ -0,0 +1,49 @@
+package vdf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// FormatSize formats n bytes in binary units, e.g. "3.5 GiB".
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// ParseSize parses a size like "4G", "700MiB" or "100MB".
+// Single letter and "iB" suffixes are binary units, "B" suffixes
+// like "MB" are decimal units as used by most upload limits.
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	num := strings.TrimRightFunc(s, unicode.IsLetter)
+	unit := strings.ToUpper(strings.TrimSpace(s[len(num):]))
+	num = strings.TrimSpace(num)
+
+	value, err := strconv.ParseFloat(num, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	multipliers := map[string]float64{
+		"": 1, "B": 1,
+		"K": 1 << 10, "KIB": 1 << 10, "KB": 1e3,
+		"M": 1 << 20, "MIB": 1 << 20, "MB": 1e6,
+		"G": 1 << 30, "GIB": 1 << 30, "GB": 1e9,
+		"T": 1 << 40, "TIB": 1 << 40, "TB": 1e12,
+	}
+	m, ok := multipliers[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q, unknown unit %q", s, unit)
+	}
+	return int64(value * m), nil
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package vdf
+
+import "os"
+
+// syncDir flushes the directory entries of dir, making renames within it durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
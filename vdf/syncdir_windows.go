@@ -0,0 +1,7 @@
+package vdf
+
+// syncDir does nothing, directories can not be flushed on Windows
+// and renames are written through by NTFS.
+func syncDir(dir string) error {
+	return nil
+}
@@ -1,24 +1,24 @@
 package vdf
 
-// v2 types
-type size_t uint32
-type time_t uint32
+import (
+	"bytes"
+	"strings"
+	"time"
+)
 
-// v3 types
-// type size_t uint64
-// type time_t uint64
+// Sizes, offsets and timestamps are kept in 64 bit, they are
+// narrowed to 32 bit when reading or writing FormatV2 archives.
+type size_t uint64
+type time_t uint64
 
 type EntryName [0x3F + 1]byte
 
-// func (c EntryName) String() string {
-// 	if i := bytes.IndexByte(c[:], 0x00); i != -1 {
-// 		return "ROOT"
-// 	}
-// 	return strings.TrimRight(string([]byte(c[:0x3F])), " ")
-// }
-// func (c EntryName) MarshalText() ([]byte, error) {
-// 	return []byte(c.String()), nil
-// }
+func (c EntryName) String() string {
+	return strings.TrimRight(string(c[:]), " \x00")
+}
+func (c EntryName) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
 
 type EntryMetadata struct {
 	Name    EntryName
@@ -30,29 +30,29 @@ type EntryMetadata struct {
 
 type Comment [0xFF + 1]byte
 
-// func (c Comment) String() string {
-// 	if i := bytes.IndexByte(c[:], 0x1A); i != -1 {
-// 		return string([]byte(c[:i]))
-// 	}
-// 	return string([]byte(c[:0xFF]))
-// }
+func (c Comment) String() string {
+	if i := bytes.IndexAny(c[:], "\x1A\x00"); i != -1 {
+		return string(c[:i])
+	}
+	return string(c[:0xFF])
+}
 
-// func (c Comment) MarshalText() ([]byte, error) {
-// 	return []byte(c.String()), nil
-// }
+func (c Comment) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
 
 type Version [0x0F + 1]byte
 
-// func (c Version) String() string {
-// 	if i := bytes.IndexByte(c[:], 0x0A); i != -1 {
-// 		return string([]byte(c[:i]))
-// 	}
-// 	return string([]byte(c[:0x0F]))
-// }
+func (c Version) String() string {
+	if i := bytes.IndexAny(c[:], "\r\n\x00"); i != -1 {
+		return string(c[:i])
+	}
+	return string(c[:])
+}
 
-// func (c Version) MarshalText() (text []byte, err error) {
-// 	return []byte(c.String()), nil
-// }
+func (c Version) MarshalText() (text []byte, err error) {
+	return []byte(c.String()), nil
+}
 
 type Params struct {
 	EntryCount  uint32
@@ -68,6 +68,20 @@ type Header struct {
 	Params  Params
 }
 
+// Time decodes the timestamp of the archive, a FAT timestamp
+// for FormatV2 and a unix timestamp for FormatV3.
+// It returns the zero time if the timestamp is invalid, see ParseTime.
+func (h Header) Time() time.Time {
+	t, _ := h.ParseTime()
+	return t
+}
+
+// ParseTime decodes the timestamp of the archive like Time,
+// but reports timestamps which do not denote a valid date.
+func (h Header) ParseTime() (time.Time, error) {
+	return h.Format().time(h.Params.TimeStamp)
+}
+
 type EntryFlag uint32
 
 const (
@@ -75,6 +89,12 @@ const (
 	EntryFlagLastEntry EntryFlag = 0x40000000
 )
 
+// String returns "D" for directories and "L" for the last entry of a directory,
+// or "-" if the flag is not set.
+func (f EntryFlag) String() string {
+	return flagString(uint32(f), []uint32{uint32(EntryFlagDirectory), uint32(EntryFlagLastEntry)}, "DL")
+}
+
 type EntryAttrib uint32
 
 const (
@@ -88,3 +108,23 @@ const (
 		EntryAttribSystem |
 		EntryAttribArchive
 )
+
+// String returns the attributes in the style of "RHSA", using "-" for unset attributes.
+func (a EntryAttrib) String() string {
+	return flagString(uint32(a), []uint32{
+		uint32(EntryAttribReadOnly),
+		uint32(EntryAttribHidden),
+		uint32(EntryAttribSystem),
+		uint32(EntryAttribArchive),
+	}, "RHSA")
+}
+
+func flagString(v uint32, bits []uint32, letters string) string {
+	b := []byte(strings.Repeat("-", len(bits)))
+	for i, bit := range bits {
+		if v&bit != 0 {
+			b[i] = letters[i]
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,242 @@
+package vdf
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Problem describes a structural defect of an archive.
+type Problem struct {
+	// Index is the table index of the affected entry, or -1 if the
+	// problem concerns the header.
+	Index int
+	// Path is the path of the affected entry, if it is known.
+	Path    string
+	Message string
+}
+
+func (p Problem) String() string {
+	switch {
+	case p.Index < 0:
+		return "header: " + p.Message
+	case p.Path != "":
+		return fmt.Sprintf("%s (entry %d): %s", p.Path, p.Index, p.Message)
+	default:
+		return fmt.Sprintf("entry %d: %s", p.Index, p.Message)
+	}
+}
+
+// VerifyFile checks the archive at path for structural problems, see Verify.
+func VerifyFile(path string) ([]Problem, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return Verify(f, fi.Size())
+}
+
+// Verify checks the archive in r for problems the game would choke on.
+// Unlike NewReader it does not stop at the first defect but reports
+// every problem it finds. The error is only set if r could not be read.
+func Verify(r io.ReaderAt, size int64) ([]Problem, error) {
+	var problems []Problem
+	report := func(index int, path, format string, args ...any) {
+		problems = append(problems, Problem{Index: index, Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	header, err := readHeader(r, size)
+	if err != nil {
+		report(-1, "", "%v", err)
+		return problems, nil
+	}
+
+	format := header.Format()
+	headerSize := format.headerSize()
+	entrySize := format.entrySize()
+	params := header.Params
+	if params.TableOffset != headerSize {
+		report(-1, "", "table offset is %d, expected %d", params.TableOffset, headerSize)
+	}
+	if params.EntrySize != entrySize {
+		report(-1, "", "entry size is %d, expected %d", params.EntrySize, entrySize)
+	}
+	if _, err := header.ParseTime(); err != nil {
+		report(-1, "", "%v", err)
+	}
+
+	count := int64(params.EntryCount)
+	tableStart := int64(params.TableOffset)
+	if avail := (size - tableStart) / int64(entrySize); tableStart > size || avail < count {
+		report(-1, "", "entry table of %d entries exceeds the archive", count)
+		count = max(0, avail)
+	}
+	tableEnd := tableStart + count*int64(entrySize)
+
+	table, err := decodeTable(io.NewSectionReader(r, tableStart, tableEnd-tableStart), format, count)
+	if err != nil {
+		return problems, fmt.Errorf("failed to read entry table. %w", err)
+	}
+	if len(table) == 0 {
+		if params.FileCount != 0 {
+			report(-1, "", "file count is %d, but the archive has no entries", params.FileCount)
+		}
+		return problems, nil
+	}
+
+	paths := make([]string, len(table))
+	visited := make([]bool, len(table))
+	type dir struct {
+		index int
+		start uint64
+	}
+	// walk the directory graph without recursion, a malicious table
+	// could otherwise nest deep enough to exhaust the stack.
+	pending := []dir{{index: -1, start: 0}}
+	reachable, files := 0, 0
+	for len(pending) > 0 {
+		d := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		parent := ""
+		if d.index >= 0 {
+			parent = paths[d.index]
+		}
+
+		terminated := false
+		for i := d.start; i < uint64(len(table)); i++ {
+			if visited[i] {
+				report(d.index, parent, "directory graph contains a loop, entry %d is reached twice", i)
+				terminated = true
+				break
+			}
+			visited[i] = true
+			reachable++
+
+			e := table[i]
+			paths[i] = e.Name.String()
+			if parent != "" {
+				paths[i] = parent + "/" + paths[i]
+			}
+
+			if e.Flags&EntryFlagDirectory != 0 {
+				if uint64(e.Offset) >= uint64(len(table)) {
+					report(int(i), paths[i], "directory offset %d points outside of the table", e.Offset)
+				} else {
+					pending = append(pending, dir{index: int(i), start: uint64(e.Offset)})
+				}
+			} else {
+				files++
+				if exceedsSize(e, size) {
+					report(int(i), paths[i], "data of %d bytes at %d exceeds the archive size of %d", e.Size, e.Offset, size)
+				} else if e.Size > 0 && uint64(e.Offset) < uint64(tableEnd) {
+					report(int(i), paths[i], "data range %d-%d overlaps the header or entry table", e.Offset, e.Offset+e.Size)
+				}
+			}
+
+			if e.Flags&EntryFlagLastEntry != 0 {
+				terminated = true
+				break
+			}
+		}
+		if !terminated {
+			if d.index < 0 {
+				report(-1, "", "root directory is missing a last entry flag")
+			} else {
+				report(d.index, parent, "directory is missing a last entry flag")
+			}
+		}
+	}
+
+	for i, ok := range visited {
+		if !ok {
+			report(i, "", "entry %q is not reachable from the root", table[i].Name.String())
+		}
+	}
+	if reachable != len(table) {
+		report(-1, "", "entry count is %d, but only %d entries are reachable", params.EntryCount, reachable)
+	}
+	if uint32(files) != params.FileCount {
+		report(-1, "", "file count is %d, but %d file entries are reachable", params.FileCount, files)
+	}
+	return problems, nil
+}
+
+// VerifyOutput re-opens the archives written by the last Execute and
+// checks their structure as well as that every entry holds the same bytes
+// as its source file in BaseDir, deduplicated entries included.
+func (vm *VM) VerifyOutput() error {
+	if vm.volumes == nil {
+		return errors.New("nothing to verify, the archive has not been built")
+	}
+	var errs []error
+	for _, v := range vm.volumes {
+		if err := vm.verifyVolume(v); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (vm *VM) verifyVolume(v Volume) error {
+	problems, err := VerifyFile(v.Name)
+	if err != nil {
+		return fmt.Errorf("failed to verify %q. %w", v.Name, err)
+	}
+	if len(problems) != 0 {
+		errs := make([]error, len(problems))
+		for i, p := range problems {
+			errs[i] = errors.New(p.String())
+		}
+		return fmt.Errorf("%q is damaged. %w", v.Name, errors.Join(errs...))
+	}
+
+	r, err := Open(v.Name)
+	if err != nil {
+		return fmt.Errorf("failed to open %q. %w", v.Name, err)
+	}
+	defer r.Close()
+
+	if len(r.Files) != len(v.table) {
+		return fmt.Errorf("%q has %d entries, expected %d", v.Name, len(r.Files), len(v.table))
+	}
+
+	var errs []error
+	for i, e := range v.table {
+		if e.Flags&EntryFlagDirectory != 0 {
+			continue
+		}
+		if err := verifyEntry(r.Files[i], filepath.Join(vm.BaseDir, e.Path)); err != nil {
+			errs = append(errs, fmt.Errorf("%q: %w", e.Path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func verifyEntry(f *File, sourcePath string) error {
+	src, err := os.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	want, err := hashFile(src)
+	if err != nil {
+		return fmt.Errorf("failed to hash source. %w", err)
+	}
+	hasher := getHasher()
+	if _, err := io.Copy(hasher, f.Open()); err != nil {
+		return fmt.Errorf("failed to hash archive entry. %w", err)
+	}
+	if got := hex.EncodeToString(hasher.Sum(nil)); got != want {
+		return fmt.Errorf("archive entry %q differs from source (sha256 %s, expected %s)", f.Path, got, want)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package vdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyAcceptsWrittenArchive(t *testing.T) {
+	archive := buildTestArchive(t, map[string]string{
+		"a.txt":            "a",
+		"_work/data/b.txt": "b",
+		"_work/data/c.txt": "a",
+	})
+	problems, err := VerifyFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range problems {
+		t.Errorf("unexpected problem: %s", p)
+	}
+}
+
+func TestVerifyReportsEveryProblem(t *testing.T) {
+	archive := buildTestArchive(t, map[string]string{
+		"a.txt":            "a",
+		"b.txt":            "b",
+		"_work/data/c.txt": "c",
+	})
+	data, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tableOffset := int(FormatV2.headerSize())
+	entrySize := int(FormatV2.entrySize())
+	entry := func(i int) []byte { return data[tableOffset+i*entrySize:] }
+
+	// root: _WORK, A.TXT, B.TXT
+	// data past the end of the archive
+	binary.LittleEndian.PutUint32(entry(1)[68:], 1<<20)
+	// data overlapping the entry table
+	binary.LittleEndian.PutUint32(entry(2)[64:], 0)
+	// directory offset outside of the table
+	binary.LittleEndian.PutUint32(entry(0)[64:], 1000)
+	// wrong file count
+	binary.LittleEndian.PutUint32(data[len(Comment{})+len(Version{})+4:], 42)
+
+	problems, err := Verify(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"exceeds the archive size",
+		"overlaps the header or entry table",
+		"points outside of the table",
+		"file count is 42",
+		"not reachable from the root",
+	}
+	for _, w := range want {
+		found := false
+		for _, p := range problems {
+			found = found || strings.Contains(p.String(), w)
+		}
+		if !found {
+			t.Errorf("expected a problem containing %q, got %v", w, problems)
+		}
+	}
+}
+
+func TestVerifyReportsOverflowingV3Entries(t *testing.T) {
+	data := rawArchive(t, FormatV3, []EntryMetadata{
+		{Name: entryName("A.TXT"), Offset: 1 << 63, Size: 1 << 63, Flags: EntryFlagLastEntry},
+	})
+	problems, err := Verify(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertCount(t, problems, 1)
+	if !strings.Contains(problems[0].Message, "exceeds the archive size") {
+		t.Errorf("expected the data to exceed the archive, got %v", problems)
+	}
+}
+
+func TestVerifyDetectsLoops(t *testing.T) {
+	archive := buildTestArchive(t, map[string]string{
+		"_work/data/c.txt": "c",
+	})
+	data, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tableOffset := int(FormatV2.headerSize())
+	entrySize := int(FormatV2.entrySize())
+
+	// let _WORK/DATA point back to the root
+	binary.LittleEndian.PutUint32(data[tableOffset+1*entrySize+64:], 0)
+
+	problems, err := Verify(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, p := range problems {
+		found = found || strings.Contains(p.Message, "loop")
+	}
+	if !found {
+		t.Errorf("expected a loop to be reported, got %v", problems)
+	}
+}
+
+func TestVerifyOutputComparesSources(t *testing.T) {
+	base := t.TempDir()
+	writeTestFiles(t, base, map[string]string{
+		"a.txt":            "a",
+		"_work/data/b.txt": "b",
+		"_work/data/c.txt": "a",
+	})
+	vm := &VM{
+		BaseDir: base,
+		VDFName: filepath.Join(t.TempDir(), "test.vdf"),
+		Files:   []string{"* -r"},
+	}
+	if err := vm.VerifyOutput(); err == nil {
+		t.Errorf("expected an error before the archive was built")
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.VerifyOutput(); err != nil {
+		t.Errorf("unexpected error. %v", err)
+	}
+
+	writeTestFiles(t, base, map[string]string{"a.txt": "changed"})
+	err := vm.VerifyOutput()
+	if err == nil {
+		t.Fatalf("expected an error after changing a source file")
+	}
+	if !strings.Contains(err.Error(), "A.TXT") {
+		t.Errorf("expected the changed file to be reported, got %v", err)
+	}
+}
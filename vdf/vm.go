@@ -4,10 +4,12 @@ import (
 	"bufio"
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 type parserState int
@@ -54,6 +56,12 @@ func switchState(state parserState, buffer []byte) (bool, parserState) {
 	return state != newState, newState
 }
 
+// ScriptTimestamp returns the Timestamp= of the script as written, empty if
+// there is none. Timestamp holds it interpreted as UTC.
+func (vm *VM) ScriptTimestamp() string {
+	return vm.scriptTimestamp
+}
+
 func ParseVM(path string) (*VM, error) {
 	f, err := os.Open(path)
 	if err != nil {
@@ -70,7 +78,9 @@ func parseVM(r io.Reader) (*VM, error) {
 		fileHashToDataOffset: make(map[string]int64),
 	}
 	state := parseInitial
+	line := 0
 	for s.Scan() {
+		line++
 		if bytes.HasPrefix(s.Bytes(), []byte(";")) {
 			continue
 		}
@@ -93,13 +103,36 @@ func parseVM(r io.Reader) (*VM, error) {
 				vm.BaseDir = string(bytes.TrimPrefix(s.Bytes(), []byte("BaseDir=")))
 			} else if bytes.HasPrefix(s.Bytes(), []byte("VDFName=")) {
 				vm.VDFName = string(bytes.TrimPrefix(s.Bytes(), []byte("VDFName=")))
+			} else if bytes.HasPrefix(s.Bytes(), []byte("Format=")) {
+				format, err := ParseFormat(string(bytes.TrimPrefix(s.Bytes(), []byte("Format="))))
+				if err != nil {
+					return nil, err
+				}
+				vm.Format = format
+			} else if bytes.HasPrefix(s.Bytes(), []byte("MaxVolumeSize=")) {
+				size, err := ParseSize(string(bytes.TrimPrefix(s.Bytes(), []byte("MaxVolumeSize="))))
+				if err != nil {
+					return nil, err
+				}
+				vm.MaxVolumeSize = size
+			} else if bytes.HasPrefix(s.Bytes(), []byte("Timestamp=")) {
+				value := string(bytes.TrimPrefix(s.Bytes(), []byte("Timestamp=")))
+				ts, err := ParseTimestamp(value, time.UTC)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse Timestamp %q. %w", value, err)
+				}
+				vm.Timestamp = ts
+				vm.scriptTimestamp = value
 			}
 		case parseFiles:
 			vm.Files = append(vm.Files, strings.ReplaceAll(s.Text(), `\`, string(filepath.Separator)))
+			vm.fileLines = append(vm.fileLines, line)
 		case parseExclude:
 			vm.Exclude = append(vm.Exclude, strings.ReplaceAll(s.Text(), `\`, string(filepath.Separator)))
+			vm.excludeLines = append(vm.excludeLines, line)
 		case parseInclude:
 			vm.Include = append(vm.Include, strings.ReplaceAll(s.Text(), `\`, string(filepath.Separator)))
+			vm.includeLines = append(vm.includeLines, line)
 		}
 	}
 	// should always be at the end
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestParsingCompleteVM(t *testing.T) {
@@ -62,6 +63,48 @@ VDFName=VDFName.vdf
 	assertEqual(t, vm.VDFName, "VDFName.vdf")
 }
 
+func TestParsingFormat(t *testing.T) {
+	var content = []byte(`[BEGINVDF]
+Format=V3.00
+[ENDVDF]`)
+
+	vm, err := parseVM(bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("Failed to parse VM. %v", err)
+	}
+	assertEqual(t, vm.Format, FormatV3)
+
+	_, err = parseVM(bytes.NewReader([]byte("[BEGINVDF]\nFormat=9\n[ENDVDF]")))
+	if err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
+
+func TestParsingTimestamp(t *testing.T) {
+	var content = []byte(`[BEGINVDF]
+Timestamp=2021-11-28 12:31:40
+[ENDVDF]`)
+
+	vm, err := parseVM(bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("Failed to parse VM. %v", err)
+	}
+	assertEqual(t, vm.Timestamp, time.Date(2021, 11, 28, 12, 31, 40, 0, time.UTC))
+	assertEqual(t, vm.ScriptTimestamp(), "2021-11-28 12:31:40")
+
+	// the same formats as on the command line
+	vm, err = parseVM(bytes.NewReader([]byte("[BEGINVDF]\nTimestamp=2021-11-28T13:31:40+01:00\n[ENDVDF]")))
+	if err != nil {
+		t.Fatalf("Failed to parse VM. %v", err)
+	}
+	assertEqual(t, vm.Timestamp, time.Date(2021, 11, 28, 12, 31, 40, 0, time.UTC))
+
+	_, err = parseVM(bytes.NewReader([]byte("[BEGINVDF]\nTimestamp=yesterday\n[ENDVDF]")))
+	if err == nil {
+		t.Errorf("expected an error for an invalid timestamp")
+	}
+}
+
 func TestCommentSupportsNewLineEscapes(t *testing.T) {
 	var content = []byte(`[BEGINVDF]
 Comment=Comment=%%NWith%%NNewLines
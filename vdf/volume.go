@@ -0,0 +1,180 @@
+package vdf
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Volume describes one archive written by a build. Builds write a single
+// volume unless MaxVolumeSize splits the content over several archives.
+type Volume struct {
+	// Name is the path of the archive.
+	Name string
+	// Subtrees lists the top most directories and files held by the volume,
+	// relative to BaseDir. Directories end with a path separator.
+	Subtrees []string
+	// Files is the number of files in the volume.
+	Files int
+	// Size is the size of the archive without deduplication.
+	Size int64
+
+	root  *dirEntry
+	table vdfsTable
+}
+
+// Volumes returns the archives written by the last Execute or fingerprinted by the last Fingerprint.
+func (vm *VM) Volumes() []Volume {
+	return vm.volumes
+}
+
+// Inputs returns the source files packed by the last Execute or Fingerprint in archive order,
+// each path is the BaseDir joined with the path of the file below it.
+func (vm *VM) Inputs() []string {
+	var inputs []string
+	var walk func(d *dirEntry, path string)
+	walk = func(d *dirEntry, path string) {
+		for _, sub := range d.Dirs {
+			walk(sub, filepath.Join(path, sub.Name))
+		}
+		for _, f := range d.Files {
+			inputs = append(inputs, filepath.Join(path, f.Name))
+		}
+	}
+	for _, v := range vm.volumes {
+		walk(v.root, vm.BaseDir)
+	}
+	return inputs
+}
+
+// VolumeName returns "Name.vdf" for the first and "Name_<n>.vdf" for any further volume.
+func VolumeName(name string, n int) string {
+	if n == 1 {
+		return name
+	}
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "_" + strconv.Itoa(n) + ext
+}
+
+// volumeSplitter distributes a dirEntry tree over volumes of a limited size,
+// keeping directories together whenever they fit into a single volume.
+type volumeSplitter struct {
+	format  Format
+	limit   int64
+	volumes []*Volume
+	size    int64 // of the last volume
+}
+
+func splitVolumes(root *dirEntry, format Format, limit int64, name string) ([]*Volume, error) {
+	s := &volumeSplitter{format: format, limit: limit}
+	s.newVolume()
+	if err := s.addChildren(root, nil); err != nil {
+		return nil, err
+	}
+	for i, v := range s.volumes {
+		v.Name = VolumeName(name, i+1)
+		v.Size, _ = v.root.numEntries()
+		v.Files = v.root.numFiles()
+	}
+	return s.volumes, nil
+}
+
+func (s *volumeSplitter) newVolume() {
+	s.volumes = append(s.volumes, &Volume{root: &dirEntry{}})
+	s.size = int64(s.format.headerSize())
+}
+
+func (s *volumeSplitter) cost(dataSize int64, entries int) int64 {
+	return dataSize + int64(entries)*int64(s.format.entrySize())
+}
+
+func (s *volumeSplitter) addChildren(d *dirEntry, parents []*dirEntry) error {
+	for _, v := range d.Dirs {
+		size, entries := v.numEntries()
+		if err := s.add(parents, v, nil, s.cost(size, entries+1)); err != nil {
+			return err
+		}
+	}
+	for _, v := range d.Files {
+		if err := s.add(parents, nil, v, s.cost(v.Size, 1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add places either the directory or the file into the current volume,
+// starting a new one or descending into the directory if it does not fit.
+func (s *volumeSplitter) add(parents []*dirEntry, dir *dirEntry, file *fileEntry, cost int64) error {
+	if total := cost + s.cost(0, s.missingDirs(parents)); s.size+total <= s.limit {
+		s.insert(parents, dir, file, total)
+		return nil
+	}
+
+	// start over with an empty volume if it fits into one, unless the current
+	// one is still empty. Otherwise the directory is split starting in the
+	// current volume, to not leave it almost empty.
+	total := cost + s.cost(0, len(parents))
+	if s.size > int64(s.format.headerSize()) && int64(s.format.headerSize())+total <= s.limit {
+		s.newVolume()
+		s.insert(parents, dir, file, total)
+		return nil
+	}
+
+	if dir == nil {
+		return fmt.Errorf("file %q (%s) does not fit into a volume of %s",
+			filepath.Join(append(dirNames(parents), file.Name)...), FormatSize(file.Size), FormatSize(s.limit))
+	}
+	return s.addChildren(dir, append(parents, dir))
+}
+
+// missingDirs returns the number of parents which do not exist in the current volume yet.
+func (s *volumeSplitter) missingDirs(parents []*dirEntry) int {
+	d := s.volumes[len(s.volumes)-1].root
+	for i, p := range parents {
+		if d = findDir(d, p.Name); d == nil {
+			return len(parents) - i
+		}
+	}
+	return 0
+}
+
+func (s *volumeSplitter) insert(parents []*dirEntry, dir *dirEntry, file *fileEntry, cost int64) {
+	v := s.volumes[len(s.volumes)-1]
+	d := v.root
+	for _, p := range parents {
+		next := findDir(d, p.Name)
+		if next == nil {
+			next = &dirEntry{Name: p.Name, Attr: p.Attr}
+			d.addDir(next)
+		}
+		d = next
+	}
+	path := filepath.Join(dirNames(parents)...)
+	if dir != nil {
+		d.addDir(dir)
+		v.Subtrees = append(v.Subtrees, filepath.Join(path, dir.Name)+string(filepath.Separator))
+	} else {
+		d.addFile(file)
+		v.Subtrees = append(v.Subtrees, filepath.Join(path, file.Name))
+	}
+	s.size += cost
+}
+
+func findDir(d *dirEntry, name string) *dirEntry {
+	for _, v := range d.Dirs {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+func dirNames(dirs []*dirEntry) []string {
+	names := make([]string, len(dirs))
+	for i, d := range dirs {
+		names[i] = d.Name
+	}
+	return names
+}
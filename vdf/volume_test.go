@@ -0,0 +1,179 @@
+package vdf
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecuteSplitsIntoVolumes(t *testing.T) {
+	base := t.TempDir()
+	writeTestFiles(t, base, map[string]string{
+		"A/a1":  strings.Repeat("1", 1000),
+		"A/a2":  strings.Repeat("2", 1000),
+		"B/b1":  strings.Repeat("3", 1500),
+		"C/c1":  strings.Repeat("4", 2000),
+		"C/c2":  strings.Repeat("5", 2000),
+		"r.txt": strings.Repeat("6", 100),
+	})
+	ts := time.Date(2021, 11, 28, 12, 31, 40, 0, time.UTC)
+	vm := &VM{
+		Comment:       "split",
+		BaseDir:       base,
+		VDFName:       filepath.Join(t.TempDir(), "Pack.vdf"),
+		Timestamp:     ts,
+		Files:         []string{"* -r"},
+		MaxVolumeSize: 3000,
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.VerifyOutput(); err != nil {
+		t.Fatal(err)
+	}
+
+	sep := string(filepath.Separator)
+	volumes := vm.Volumes()
+	want := [][]string{
+		{"A" + sep},
+		{"B" + sep},
+		{filepath.Join("C", "c1")},
+		{filepath.Join("C", "c2"), "r.txt"},
+	}
+	assertCount(t, volumes, len(want))
+	for i, v := range volumes {
+		if !slices.Equal(v.Subtrees, want[i]) {
+			t.Errorf("volume %d holds %v, expected %v", i+1, v.Subtrees, want[i])
+		}
+		assertEqual(t, filepath.Base(v.Name), VolumeName("Pack.vdf", i+1))
+
+		r, err := Open(v.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, r.Header.Comment.String(), "split")
+		assertEqual(t, r.Header.Time(), ts)
+		assertEqual(t, int(r.Header.Params.FileCount), v.Files)
+		if r.Size() > vm.MaxVolumeSize {
+			t.Errorf("volume %d is %d bytes, larger than %d", i+1, r.Size(), vm.MaxVolumeSize)
+		}
+		r.Close()
+	}
+
+	r, err := Open(volumes[3].Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := fs.Stat(r, "C/C2"); err != nil {
+		t.Errorf("expected the directory to be recreated in a later volume. %v", err)
+	}
+}
+
+func TestExecuteSplitsDirectoriesLargerThanAVolumeInPlace(t *testing.T) {
+	base := t.TempDir()
+	writeTestFiles(t, base, map[string]string{
+		"_WORK/DATA/SND/x.wav": "wav",
+		"_WORK/DATA/TEX/a.tex": strings.Repeat("a", 3000),
+		"_WORK/DATA/TEX/b.tex": strings.Repeat("b", 3000),
+	})
+	vm := &VM{
+		BaseDir:       base,
+		VDFName:       filepath.Join(t.TempDir(), "Pack.vdf"),
+		Timestamp:     time.Date(2021, 11, 28, 12, 31, 40, 0, time.UTC),
+		Files:         []string{"* -r"},
+		MaxVolumeSize: 4000,
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	// TEX does not fit into any volume, so it is split starting next to SND
+	tex := filepath.Join("_WORK", "DATA", "TEX")
+	want := [][]string{
+		{filepath.Join("_WORK", "DATA", "SND") + string(filepath.Separator), filepath.Join(tex, "a.tex")},
+		{filepath.Join(tex, "b.tex")},
+	}
+	volumes := vm.Volumes()
+	assertCount(t, volumes, len(want))
+	for i, v := range volumes {
+		if !slices.Equal(v.Subtrees, want[i]) {
+			t.Errorf("volume %d holds %v, expected %v", i+1, v.Subtrees, want[i])
+		}
+	}
+}
+
+func TestExecuteReportsReplacedVolumes(t *testing.T) {
+	base := t.TempDir()
+	writeTestFiles(t, base, map[string]string{
+		"A/a": strings.Repeat("a", 2000),
+		"B/b": strings.Repeat("b", 2000),
+	})
+	out := t.TempDir()
+	vm := &VM{
+		BaseDir:       base,
+		VDFName:       filepath.Join(out, "Pack.vdf"),
+		Files:         []string{"* -r"},
+		MaxVolumeSize: 3000,
+	}
+	// the second volume can not be renamed into place
+	if err := os.MkdirAll(filepath.Join(out, "Pack_2.vdf", "in the way"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	err := vm.Execute()
+	var be *BuildError
+	if !errors.As(err, &be) || be.Path != filepath.Join(out, "Pack_2.vdf") {
+		t.Fatalf("expected the second volume to fail, got %v", err)
+	}
+	if !strings.Contains(err.Error(), fmt.Sprintf("%q already replaced", vm.VDFName)) {
+		t.Errorf("expected the first volume to be reported as replaced, got %v", err)
+	}
+}
+
+func TestExecuteFailsForFilesLargerThanAVolume(t *testing.T) {
+	base := t.TempDir()
+	writeTestFiles(t, base, map[string]string{"big": strings.Repeat("x", 2000)})
+	vm := &VM{
+		BaseDir:       base,
+		VDFName:       filepath.Join(t.TempDir(), "Pack.vdf"),
+		Files:         []string{"* -r"},
+		MaxVolumeSize: 1000,
+	}
+	if err := vm.Execute(); err == nil || !strings.Contains(err.Error(), "does not fit") {
+		t.Errorf("expected an error for an oversized file, got %v", err)
+	}
+}
+
+func TestVolumeName(t *testing.T) {
+	assertEqual(t, VolumeName("Pack.vdf", 1), "Pack.vdf")
+	assertEqual(t, VolumeName("Pack.vdf", 2), "Pack_2.vdf")
+	assertEqual(t, VolumeName(filepath.Join("out", "Pack.mod"), 3), filepath.Join("out", "Pack_3.mod"))
+}
+
+func TestParseSize(t *testing.T) {
+	for in, want := range map[string]int64{
+		"123":     123,
+		"4G":      4 << 30,
+		"1.5 GiB": 3 << 29,
+		"700MB":   700e6,
+		"64k":     64 << 10,
+	} {
+		got, err := ParseSize(in)
+		if err != nil {
+			t.Errorf("ParseSize(%q) failed. %v", in, err)
+		}
+		assertEqual(t, got, want)
+	}
+	for _, in := range []string{"", "G", "12X", "-1"} {
+		if _, err := ParseSize(in); err == nil {
+			t.Errorf("expected an error for %q", in)
+		}
+	}
+}
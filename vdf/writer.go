@@ -1,9 +1,11 @@
 package vdf
 
 import (
+	"cmp"
+	"context"
 	"crypto/sha256"
-	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"hash"
 	"io"
@@ -22,6 +24,27 @@ type VM struct {
 	BaseDir   string
 	VDFName   string
 	Timestamp time.Time
+	// TimestampPolicy decides what happens if the format can not store
+	// Timestamp, e.g. years before 1980 in FormatV2. The default clamps.
+	TimestampPolicy TimestampPolicy
+	// Format of the archive, FormatV2 if unset.
+	Format Format
+	// MaxVolumeSize splits the content into several archives of at most
+	// this many bytes, named "Name.vdf", "Name_2.vdf" and so on.
+	// Directories are kept together where possible. Zero disables splitting.
+	MaxVolumeSize int64
+	// KeepBackup renames an existing VDFName to "<VDFName>.bak"
+	// instead of replacing it.
+	KeepBackup bool
+	// Workers is the number of files read and hashed at the same time
+	// while the data is written, runtime.NumCPU() if zero.
+	Workers int
+	// OnMaskDecision is called for every file found while searching,
+	// with the masks deciding whether the file is packed.
+	OnMaskDecision func(MaskDecision)
+	// HashCache skips hashing source files whose size and modification time
+	// did not change since the last build. Nil hashes every file.
+	HashCache *HashCache
 
 	Files   []string
 	Exclude []string
@@ -31,6 +54,13 @@ type VM struct {
 	excludeMasks         []*regexp.Regexp
 	includeMasks         []*regexp.Regexp
 	fileHashToDataOffset map[string]int64
+
+	// lines of the masks within the script, if parsed from one
+	fileLines, excludeLines, includeLines []int
+	// archives of the last Execute, used by VerifyOutput
+	volumes []Volume
+	// Timestamp= of the script, if parsed from one
+	scriptTimestamp string
 }
 
 type fileEntry struct {
@@ -38,6 +68,7 @@ type fileEntry struct {
 	Flags         EntryFlag
 	Attr          EntryAttrib
 	Size          int64
+	ModTime       time.Time
 }
 type dirEntry struct {
 	Name  string
@@ -66,6 +97,14 @@ func (d *dirEntry) numEntries() (int64, int) {
 	return fullSize, entries
 }
 
+func (d *dirEntry) numFiles() int {
+	n := len(d.Files)
+	for _, v := range d.Dirs {
+		n += v.numFiles()
+	}
+	return n
+}
+
 func getFileAttr(entry fs.DirEntry) EntryAttrib {
 	if entry.IsDir() {
 		return 0 // same as GothicVDFS
@@ -88,33 +127,41 @@ func getFileAttr(entry fs.DirEntry) EntryAttrib {
 // mimics GothicVDFS by either matching any INCLUDE
 // or matching a FILES before possibly EXCLUDE'ing it
 func (vm *VM) matchesMasks(relativePath string) bool {
-	relativePath = filepath.ToSlash(relativePath)
-
-	// If it's explicitly included, ignore all other masks
-	if slices.ContainsFunc(vm.includeMasks, func(rx *regexp.Regexp) bool {
-		return rx.MatchString(relativePath)
-	}) {
-		return true
+	m := vm.matchMasks(relativePath)
+	if vm.OnMaskDecision != nil {
+		vm.OnMaskDecision(vm.decision(relativePath, m))
 	}
+	return m.included()
+}
+
+// maskMatch holds the index of the first mask of each section matching a path, -1 if none does.
+type maskMatch struct {
+	include, files, exclude int
+}
 
-	// First try to include any file that matches [FILES]
-	shouldInclude := slices.ContainsFunc(vm.fileMasks, func(rx *regexp.Regexp) bool {
-		return rx.MatchString(relativePath)
-	})
+// included applies the precedence of the sections: [INCLUDE] overrides everything,
+// otherwise a path has to match [FILES] but not [EXCLUDE].
+func (m maskMatch) included() bool {
+	return m.include >= 0 || (m.files >= 0 && m.exclude < 0)
+}
 
-	if shouldInclude {
-		// then figure out if it should be [EXCLUDE]d
-		if slices.ContainsFunc(vm.excludeMasks, func(rx *regexp.Regexp) bool {
+func (vm *VM) matchMasks(relativePath string) maskMatch {
+	relativePath = filepath.ToSlash(relativePath)
+	first := func(masks []*regexp.Regexp) int {
+		return slices.IndexFunc(masks, func(rx *regexp.Regexp) bool {
 			return rx.MatchString(relativePath)
-		}) {
-			shouldInclude = false
-		}
+		})
 	}
-
-	return shouldInclude
+	m := maskMatch{include: first(vm.includeMasks), files: first(vm.fileMasks), exclude: -1}
+	if m.files >= 0 {
+		m.exclude = first(vm.excludeMasks)
+	}
+	return m
 }
 
-func (vm *VM) searchFiles(root, path string, list *dirEntry) int {
+// searchFiles collects all files matching the masks into list.
+// It does not stop at unreadable entries, all failures are returned combined.
+func (vm *VM) searchFiles(ctx context.Context, root, path string, list *dirEntry) (int, error) {
 	fileCount := 0
 	fullPath := root
 	result := 0
@@ -123,13 +170,18 @@ func (vm *VM) searchFiles(root, path string, list *dirEntry) int {
 	}
 	entries, err := os.ReadDir(fullPath)
 	if err != nil {
-		panic(err)
+		return 0, &BuildError{Phase: PhaseSearch, Path: fullPath, Err: err}
 	}
+	var errs []error
 	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
 		name := entry.Name()
 		info, err := entry.Info()
 		if err != nil {
-			panic(err)
+			errs = append(errs, &BuildError{Phase: PhaseSearch, Path: filepath.Join(fullPath, name), Err: err})
+			continue
 		}
 		subPath := filepath.Join(path, entry.Name())
 
@@ -140,7 +192,14 @@ func (vm *VM) searchFiles(root, path string, list *dirEntry) int {
 				Name: name,
 				Attr: attr,
 			}
-			if n := vm.searchFiles(root, subPath, de); n != 0 {
+			n, err := vm.searchFiles(ctx, root, subPath, de)
+			if err != nil {
+				if ctx.Err() != nil {
+					return 0, err
+				}
+				errs = append(errs, err)
+			}
+			if n != 0 {
 				list.addDir(de)
 				result += n
 			}
@@ -160,15 +219,58 @@ func (vm *VM) searchFiles(root, path string, list *dirEntry) int {
 				continue
 			}
 			fe := &fileEntry{
-				Name: name,
-				Size: info.Size(),
-				Attr: attr,
+				Name:    name,
+				Size:    info.Size(),
+				Attr:    attr,
+				ModTime: info.ModTime(),
 			}
 			list.addFile(fe)
 			fileCount++
 			result++
 		}
 	}
+	return result, errors.Join(errs...)
+}
+
+// checkLayout makes sure every size and offset of the archive fits into
+// the format before anything is written. Deduplication is not taken into
+// account, the DataSize of the header holds the full size regardless.
+func checkLayout(root *dirEntry, format Format) error {
+	dataSize, entryCount := root.numEntries()
+	size := uint64(format.headerSize()) + uint64(entryCount)*uint64(format.entrySize()) + uint64(dataSize)
+	if size <= format.maxSize() {
+		return nil
+	}
+	return &SizeLimitError{
+		Format:  format,
+		Size:    size,
+		Limit:   format.maxSize(),
+		Largest: largestContributors(root, 10),
+	}
+}
+
+// largestContributors returns the n biggest top-level directories and files.
+func largestContributors(root *dirEntry, n int) []Contributor {
+	var result []Contributor
+	for _, d := range root.Dirs {
+		size, _ := d.numEntries()
+		result = append(result, Contributor{Path: d.Name, Size: size, Dir: true})
+	}
+	var walk func(d *dirEntry, path string)
+	walk = func(d *dirEntry, path string) {
+		for _, v := range d.Dirs {
+			walk(v, filepath.Join(path, v.Name))
+		}
+		for _, v := range d.Files {
+			result = append(result, Contributor{Path: filepath.Join(path, v.Name), Size: v.Size})
+		}
+	}
+	walk(root, "")
+
+	slices.SortStableFunc(result, func(a, b Contributor) int { return cmp.Compare(b.Size, a.Size) })
+	if len(result) > n {
+		result = result[:n]
+	}
 	return result
 }
 
@@ -190,8 +292,9 @@ type ExtendedEntryMetadata struct {
 
 type vdfsTable []ExtendedEntryMetadata
 
-func (vm *VM) readFilesFromList(list *dirEntry, f *os.File, table vdfsTable, root, path string, index *uint, dataPos *size_t) bool {
-	result := true
+// readFilesFromList fills the table and collects the files whose data
+// has to be written, in table order.
+func (vm *VM) readFilesFromList(list *dirEntry, table vdfsTable, root, path string, index *uint, jobs *[]dataJob) {
 	idx := *index
 	*index += uint(len(list.Dirs) + len(list.Files))
 
@@ -210,10 +313,7 @@ func (vm *VM) readFilesFromList(list *dirEntry, f *os.File, table vdfsTable, roo
 			e.Flags |= EntryFlagLastEntry
 		}
 		table[idx] = e
-		if !vm.readFilesFromList(v, f, table, root, subPath, index, dataPos) {
-			result = false
-			break
-		}
+		vm.readFilesFromList(v, table, root, subPath, index, jobs)
 		idx++
 	}
 
@@ -222,7 +322,6 @@ func (vm *VM) readFilesFromList(list *dirEntry, f *os.File, table vdfsTable, roo
 			Path: filepath.Join(path, v.Name),
 			EntryMetadata: EntryMetadata{
 				Name:    entryName(v.Name),
-				Offset:  size_t(*dataPos),
 				Size:    size_t(v.Size),
 				Flags:   0,
 				Attribs: v.Attr,
@@ -231,106 +330,90 @@ func (vm *VM) readFilesFromList(list *dirEntry, f *os.File, table vdfsTable, roo
 			e.EntryMetadata.Flags |= EntryFlagLastEntry
 		}
 
-		if pos, ok := vm.tryGetExistingPos(root, path, v.Name); ok {
-			e.Offset = size_t(pos)
-			table[idx] = e
-			idx++
-			continue
-		}
-
 		table[idx] = e
-		hash, ok := vm.appendDataFromDisk(f, root, path, v.Name)
-		if !ok {
-			fmt.Fprintf(os.Stdout, "could not process %q\n", v.Name)
-			return false
-		}
-		vm.fileHashToDataOffset[hash] = int64(*dataPos)
-		*dataPos += e.Size
+		*jobs = append(*jobs, dataJob{index: idx, fullPath: filepath.Join(root, path, v.Name), file: v})
 		idx++
 	}
-
-	return result
 }
 
-func (vm *VM) tryGetExistingPos(root, path, name string) (int64, bool) {
-	fullPath := filepath.Join(root, path, name)
-	src, err := os.Open(fullPath)
-	if err != nil {
-		return 0, false
-	}
-	defer src.Close()
+// contextReader fails reads once ctx is cancelled,
+// so copying large files can be interrupted.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
 
-	hash, err := hashFile(src)
-	if err != nil {
-		return 0, false
-	}
-	if pos, ok := vm.fileHashToDataOffset[hash]; ok {
-		return pos, true
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
 	}
-	return 0, false
+	return r.r.Read(p)
 }
 
 func getHasher() hash.Hash {
 	return sha256.New()
 }
 
-func hashFile(f *os.File) (string, error) {
+func hashFile(r io.Reader) (string, error) {
 	hasher := getHasher()
-	if _, err := io.Copy(hasher, f); err != nil {
+	if _, err := io.Copy(hasher, r); err != nil {
 		return "", err
 	}
 
 	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
-func (vm *VM) appendDataFromDisk(f *os.File, root, path, name string) (string, bool) {
-	fullPath := filepath.Join(root, path, name)
+// appendDataFromDisk copies the file to the current position of f and returns the hash of its content.
+// A known hash, e.g. from the HashCache, is returned without hashing the content again.
+func (vm *VM) appendDataFromDisk(ctx context.Context, f *os.File, fullPath string, file *fileEntry, knownHash string) (string, error) {
 	src, err := os.Open(fullPath)
 	if err != nil {
-		return "", false
+		return "", &BuildError{Phase: PhaseRead, Path: fullPath, Err: err}
 	}
 	defer src.Close()
 
+	var dst io.Writer = f
 	hasher := getHasher()
-	if _, err := io.Copy(io.MultiWriter(f, hasher), src); err != nil {
-		return "", false
+	if knownHash == "" {
+		dst = io.MultiWriter(f, hasher)
+	}
+	r := &readErrReader{r: contextReader{ctx, src}}
+	n, err := io.Copy(dst, r)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if r.err != nil {
+			return "", &BuildError{Phase: PhaseRead, Path: fullPath, Err: err}
+		}
+		return "", &BuildError{Phase: PhaseWrite, Path: fullPath, Err: err}
+	}
+	if n != file.Size {
+		return "", &BuildError{Phase: PhaseRead, Path: fullPath, Err: fmt.Errorf("size changed from %d to %d bytes during the build", file.Size, n)}
 	}
 
-	return hex.EncodeToString(hasher.Sum(nil)), true
+	if knownHash != "" {
+		return knownHash, nil
+	}
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	if vm.HashCache != nil {
+		vm.HashCache.store(fullPath, file.Size, file.ModTime, hash)
+	}
+	return hash, nil
 }
 
-func vdfDateTime(t time.Time) time_t {
-	// TODO: once gothic overflows 2038
-	// probably someone will mod in int64 timestamps
-	// and require re-packing all VDFs
-
-	// if unsafe.Sizeof(time_t(0)) == 8 {
-	// 	return time_t(t.Unix())
-	// }
-
-	// calculate Fat DateTime
-
-	/* https://stackoverflow.com/a/15763512
-
-				   24                16                 8                 0
-	+-+-+-+-+-+-+-+-+ +-+-+-+-+-+-+-+-+ +-+-+-+-+-+-+-+-+ +-+-+-+-+-+-+-+-+
-	|Y|Y|Y|Y|Y|Y|Y|M| |M|M|M|D|D|D|D|D| |h|h|h|h|h|m|m|m| |m|m|m|s|s|s|s|s|
-	+-+-+-+-+-+-+-+-+ +-+-+-+-+-+-+-+-+ +-+-+-+-+-+-+-+-+ +-+-+-+-+-+-+-+-+
-	 \___________/ \_______/ \_______/   \_______/ \___________/ \_______/
-		year        month       day         hour     minute        second
-
-	The year is stored as an offset from 1980.
-	Seconds are stored in two-second increments.
-	(So if the "second" value is 15, it actually represents 30 seconds.)
-	*/
+// readErrReader remembers read errors, to tell them apart from write errors of io.Copy.
+type readErrReader struct {
+	r   io.Reader
+	err error
+}
 
-	fdt := uint32(t.Year()-1980) << 25
-	fdt |= uint32(t.Month()) << 21
-	fdt |= uint32(t.Day()) << 16
-	fdt |= uint32(t.Hour()) << 11
-	fdt |= uint32(t.Minute()) << 5
-	fdt |= uint32(t.Second()) >> 1
-	return time_t(fdt)
+func (r *readErrReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
 }
 
 func comment(c string) Comment {
@@ -346,70 +429,180 @@ func comment(c string) Comment {
 	return comment
 }
 
-func (vm *VM) Execute() error {
-	basePath := vm.BaseDir
+func (vm *VM) compileMasks() {
 	vm.fileMasks = buildMasks(vm.Files)
 	vm.excludeMasks = buildMasks(vm.Exclude)
 	vm.includeMasks = buildMasks(vm.Include)
-	vm.fileHashToDataOffset = make(map[string]int64)
+}
+
+// Execute builds the archive, see ExecuteContext.
+func (vm *VM) Execute() error {
+	return vm.ExecuteContext(context.Background())
+}
 
-	f, err := os.Create(vm.VDFName)
+// ExecuteContext builds the archive. The build stops as soon as ctx is
+// cancelled, in which case no output is left behind and ctx.Err() is returned.
+func (vm *VM) ExecuteContext(ctx context.Context) error {
+	vm.volumes = nil
+	format, timestamp, volumes, err := vm.layoutVolumes(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create output. %w", err)
+		return err
 	}
-	defer f.Close()
 
-	version := Version{'P', 'S', 'V', 'D', 'S', 'C', '_', 'V', '2', '.', '0', '0', '\n', '\r', '\n', '\r'}
-	// version3 := Version{'P', 'S', 'V', 'D', 'S', 'C', '_', 'V', '3', '.', '0', '0', '\n', '\r', '\n', '\r'}
+	// volumes are only renamed into place once all of them were written
+	files := make([]*AtomicFile, len(volumes))
+	for i, v := range volumes {
+		f, err := CreateAtomic(v.Name)
+		if err != nil {
+			return &BuildError{Phase: PhaseWrite, Path: v.Name, Err: err}
+		}
+		defer f.Abort()
+		files[i] = f
+
+		if err := vm.writeVolume(ctx, f, v, format, timestamp); err != nil {
+			return err
+		}
+	}
+	// flush all volumes first, a rename failing half way through would
+	// leave a mix of old and new volumes behind
+	for i, f := range files {
+		if err := f.flush(); err != nil {
+			return &BuildError{Phase: PhaseWrite, Path: volumes[i].Name, Err: err}
+		}
+	}
+	for i, f := range files {
+		if err := f.rename(vm.KeepBackup); err != nil {
+			if i > 0 {
+				replaced := make([]string, i)
+				for j, v := range volumes[:i] {
+					replaced[j] = fmt.Sprintf("%q", v.Name)
+				}
+				err = fmt.Errorf("%w. %s already replaced by the new build", err, strings.Join(replaced, ", "))
+			}
+			return &BuildError{Phase: PhaseWrite, Path: volumes[i].Name, Err: err}
+		}
+	}
+
+	vm.volumes = make([]Volume, len(volumes))
+	for i, v := range volumes {
+		vm.volumes[i] = *v
+	}
+	return nil
+}
+
+// layoutVolumes searches the files of vm and distributes them over the volumes to write.
+func (vm *VM) layoutVolumes(ctx context.Context) (Format, time_t, []*Volume, error) {
+	vm.compileMasks()
+	format := vm.Format.orDefault()
+	timestamp, err := format.timestamp(vm.Timestamp, vm.TimestampPolicy)
+	if err != nil {
+		return format, 0, nil, err
+	}
 
 	rootEntry := &dirEntry{}
-	nFiles := vm.searchFiles(basePath, "", rootEntry)
-	dataSize, entryCount := rootEntry.numEntries()
+	nFiles, err := vm.searchFiles(ctx, vm.BaseDir, "", rootEntry)
+	if err != nil {
+		return format, 0, nil, err
+	}
+	volumes, err := vm.distribute(rootEntry, nFiles, format)
+	return format, timestamp, volumes, err
+}
+
+// distribute splits the files found by searchFiles over the volumes to write.
+func (vm *VM) distribute(rootEntry *dirEntry, nFiles int, format Format) ([]*Volume, error) {
+	dataSize, _ := rootEntry.numEntries()
+	volumes := []*Volume{{
+		Name:     vm.VDFName,
+		Subtrees: []string{"." + string(filepath.Separator)},
+		Files:    nFiles,
+		Size:     dataSize,
+		root:     rootEntry,
+	}}
+	if vm.MaxVolumeSize > 0 {
+		var err error
+		if volumes, err = splitVolumes(rootEntry, format, vm.MaxVolumeSize, vm.VDFName); err != nil {
+			return nil, err
+		}
+	}
+	for _, v := range volumes {
+		if err := checkLayout(v.root, format); err != nil {
+			return nil, err
+		}
+	}
+	return volumes, nil
+}
 
-	nowFileTime := vdfDateTime(vm.Timestamp)
-	header := Header{
+// volumeHeader returns the header of the archive holding v.
+func (vm *VM) volumeHeader(v *Volume, format Format, timestamp time_t) Header {
+	dataSize, entryCount := v.root.numEntries()
+	return Header{
 		Comment: comment(vm.Comment),
-		Version: version,
+		Version: format.version(),
 		Params: Params{
 			EntryCount:  uint32(entryCount),
-			FileCount:   uint32(nFiles),
-			TimeStamp:   nowFileTime,
+			FileCount:   uint32(v.Files),
+			TimeStamp:   timestamp,
 			DataSize:    size_t(dataSize),
-			TableOffset: uint32(unsafe.Sizeof(Header{})),
-			EntrySize:   uint32(unsafe.Sizeof(EntryMetadata{})),
+			TableOffset: format.headerSize(),
+			EntrySize:   format.entrySize(),
 		}}
-	binary.Write(f, binary.LittleEndian, header)
+}
+
+// writeVolume writes the header, data and entry table of a single archive.
+func (vm *VM) writeVolume(ctx context.Context, f *AtomicFile, v *Volume, format Format, timestamp time_t) error {
+	vm.fileHashToDataOffset = make(map[string]int64)
+	header := vm.volumeHeader(v, format, timestamp)
+	if err := writeHeader(f, format, header); err != nil {
+		return &BuildError{Phase: PhaseWrite, Path: v.Name, Err: fmt.Errorf("failed to write header. %w", err)}
+	}
 
 	tbl := make(vdfsTable, header.Params.EntryCount)
-	tableSize := header.Params.EntryCount * header.Params.EntrySize
-	dataPos := size_t(header.Params.TableOffset + tableSize)
+	tableSize := size_t(header.Params.EntryCount) * size_t(header.Params.EntrySize)
+	dataPos := size_t(header.Params.TableOffset) + tableSize
 
 	if err := f.Truncate(int64(dataPos)); err != nil {
-		return fmt.Errorf("could not truncate to fit data. %w", err)
+		return &BuildError{Phase: PhaseWrite, Path: v.Name, Err: fmt.Errorf("could not truncate to fit data. %w", err)}
 	}
 
 	if _, err := f.Seek(int64(dataPos), io.SeekStart); err != nil {
-		return fmt.Errorf("failed to seek to data offset. %w", err)
+		return &BuildError{Phase: PhaseWrite, Path: v.Name, Err: fmt.Errorf("failed to seek to data offset. %w", err)}
 	}
 
 	startIndex := uint(0)
-	vm.readFilesFromList(rootEntry, f, tbl, basePath, "", &startIndex, &dataPos)
+	var jobs []dataJob
+	vm.readFilesFromList(v.root, tbl, vm.BaseDir, "", &startIndex, &jobs)
+
+	var readErrs []error
+	if err := vm.writeData(ctx, f.File, tbl, jobs, &dataPos, &readErrs); err != nil {
+		return errors.Join(append(readErrs, err)...)
+	}
+	if len(readErrs) != 0 {
+		return errors.Join(readErrs...)
+	}
+	// a duplicate at the end leaves its rolled back copy behind
+	if err := f.Truncate(int64(dataPos)); err != nil {
+		return &BuildError{Phase: PhaseWrite, Path: v.Name, Err: fmt.Errorf("failed to truncate data. %w", err)}
+	}
 
 	if _, err := f.Seek(int64(header.Params.TableOffset), io.SeekStart); err != nil {
-		return fmt.Errorf("failed to seek to table offset. %w", err)
+		return &BuildError{Phase: PhaseWrite, Path: v.Name, Err: fmt.Errorf("failed to seek to table offset. %w", err)}
 	}
-	for _, v := range tbl {
-		if err := binary.Write(f, binary.LittleEndian, v.EntryMetadata); err != nil {
-			return fmt.Errorf("failed to write table entry. %q: %w", v.Name, err)
-		}
+	if err := writeTable(f, format, tbl); err != nil {
+		return &BuildError{Phase: PhaseWrite, Path: v.Name, Err: fmt.Errorf("failed to write entry table. %w", err)}
 	}
+	v.table = tbl
 	return nil
 }
 
+// isRecursiveMask reports if a mask ends with the "-r" switch.
+func isRecursiveMask(mask string) bool {
+	return strings.HasSuffix(mask, " -r")
+}
+
 func buildMasks(files []string) []*regexp.Regexp {
 	var result []*regexp.Regexp
 	for _, f := range files {
-		recursive := strings.HasSuffix(f, " -r")
+		recursive := isRecursiveMask(f)
 		f = strings.TrimSuffix(f, "-r")
 
 		// clean any left over spaces
@@ -0,0 +1,352 @@
+package vdf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecuteReportsUnreadableFiles(t *testing.T) {
+	base := t.TempDir()
+	writeTestFiles(t, base, map[string]string{"a.txt": "a"})
+	for _, name := range []string{"missing1.txt", "missing2.txt"} {
+		if err := os.Symlink(filepath.Join(base, "does-not-exist"), filepath.Join(base, name)); err != nil {
+			t.Skipf("symlinks are not supported. %v", err)
+		}
+	}
+
+	vm := &VM{
+		BaseDir: base,
+		VDFName: filepath.Join(t.TempDir(), "test.vdf"),
+		Files:   []string{"* -r"},
+	}
+	err := vm.Execute()
+	if err == nil {
+		t.Fatal("expected an error for unreadable files")
+	}
+
+	var be *BuildError
+	if !errors.As(err, &be) {
+		t.Fatalf("expected a BuildError, got %T: %v", err, err)
+	}
+	assertEqual(t, be.Phase, PhaseRead)
+	assertEqual(t, filepath.Base(be.Path), "missing1.txt")
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the cause to be preserved, got %v", err)
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); !ok || len(joined.Unwrap()) != 2 {
+		t.Errorf("expected both files to be reported, got %v", err)
+	}
+}
+
+func TestExecuteReportsMissingBaseDir(t *testing.T) {
+	vm := &VM{
+		BaseDir: filepath.Join(t.TempDir(), "missing"),
+		VDFName: filepath.Join(t.TempDir(), "test.vdf"),
+		Files:   []string{"* -r"},
+	}
+	err := vm.Execute()
+	var be *BuildError
+	if !errors.As(err, &be) {
+		t.Fatalf("expected a BuildError, got %T: %v", err, err)
+	}
+	assertEqual(t, be.Phase, PhaseSearch)
+	if _, err := os.Stat(vm.VDFName); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected no output to be created")
+	}
+}
+
+func TestExecuteReplacesOutputAtomically(t *testing.T) {
+	base := t.TempDir()
+	outDir := t.TempDir()
+	writeTestFiles(t, base, map[string]string{"a.txt": "first"})
+
+	vm := &VM{
+		BaseDir:    base,
+		VDFName:    filepath.Join(outDir, "test.vdf"),
+		Files:      []string{"* -r"},
+		KeepBackup: true,
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	first, err := os.ReadFile(vm.VDFName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a failing build must not touch the previous archive
+	if err := os.Symlink(filepath.Join(base, "does-not-exist"), filepath.Join(base, "broken.txt")); err != nil {
+		t.Skipf("symlinks are not supported. %v", err)
+	}
+	if err := vm.Execute(); err == nil {
+		t.Fatal("expected the build to fail")
+	}
+	assertFileContent(t, vm.VDFName, first)
+
+	// a successful build keeps the previous archive as backup
+	if err := os.Remove(filepath.Join(base, "broken.txt")); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFiles(t, base, map[string]string{"a.txt": "second"})
+	if err := vm.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContent(t, vm.VDFName+".bak", first)
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertCount(t, entries, 2)
+}
+
+func assertFileContent(t *testing.T, path string, want []byte) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("content of %q differs", path)
+	}
+}
+
+func TestExecuteContextRemovesPartialOutput(t *testing.T) {
+	base := t.TempDir()
+	writeTestFiles(t, base, map[string]string{
+		"a.txt":   "a",
+		"big.tex": strings.Repeat("x", 4<<20),
+	})
+
+	// stream big.tex, so the context is checked while it is copied
+	defer func(limit int64) { prefetchLimit = limit }(prefetchLimit)
+	prefetchLimit = 1 << 20
+
+	// cancel at various points of the build: while searching and after some of the data is written
+	for _, written := range []int64{0, 1 << 10, 1 << 20, 3 << 20} {
+		outDir := t.TempDir()
+		vm := &VM{
+			BaseDir: base,
+			VDFName: filepath.Join(outDir, "test.vdf"),
+			Files:   []string{"* -r"},
+		}
+		ctx := &cancelAfterWrite{Context: context.Background(), dir: outDir, written: written}
+		if err := vm.ExecuteContext(ctx); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled after %d bytes, got %v", written, err)
+		}
+		entries, err := os.ReadDir(outDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertCount(t, entries, 0)
+	}
+}
+
+// cancelAfterWrite reports cancellation once the files in dir hold a number of bytes.
+type cancelAfterWrite struct {
+	context.Context
+	dir     string
+	written int64
+}
+
+func (c *cancelAfterWrite) Err() error {
+	entries, _ := os.ReadDir(c.dir)
+	var size int64
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil {
+			size += info.Size()
+		}
+	}
+	if size >= c.written {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestCheckLayoutRejectsOversizedV2Archives(t *testing.T) {
+	root := &dirEntry{
+		Dirs: []*dirEntry{{
+			Name: "_WORK",
+			Dirs: []*dirEntry{{
+				Name:  "TEXTURES",
+				Files: []*fileEntry{{Name: "HUGE.TEX", Size: 3 << 30}},
+			}},
+			Files: []*fileEntry{{Name: "BIG.TEX", Size: 1 << 30}},
+		}},
+		Files: []*fileEntry{{Name: "SMALL.TXT", Size: 10}},
+	}
+
+	if err := checkLayout(root, FormatV3); err != nil {
+		t.Errorf("unexpected error for V3. %v", err)
+	}
+
+	err := checkLayout(root, FormatV2)
+	var le *SizeLimitError
+	if !errors.As(err, &le) {
+		t.Fatalf("expected a SizeLimitError, got %v", err)
+	}
+	assertEqual(t, le.Limit, uint64(math.MaxUint32))
+	assertCount(t, le.Largest, 4)
+	assertEqual(t, le.Largest[0], Contributor{Path: "_WORK", Size: 4 << 30, Dir: true})
+	assertEqual(t, le.Largest[1], Contributor{Path: filepath.Join("_WORK", "TEXTURES", "HUGE.TEX"), Size: 3 << 30})
+	if !strings.Contains(err.Error(), "HUGE.TEX") {
+		t.Errorf("expected the largest file in the report, got %v", err)
+	}
+}
+
+func TestExecuteRollsBackDuplicates(t *testing.T) {
+	base := t.TempDir()
+	unique := strings.Repeat("x", 1000)
+	writeTestFiles(t, base, map[string]string{
+		"a/data.bin": unique,
+		"b/data.bin": unique,
+		"z.bin":      unique, // the last entry, its copy must not be left behind
+	})
+	vm := &VM{
+		BaseDir: base,
+		VDFName: filepath.Join(t.TempDir(), "test.vdf"),
+		Files:   []string{"* -r"},
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(vm.VDFName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	// A, B, Z.BIN, A/DATA.BIN, B/DATA.BIN and the data once
+	dataStart := int64(FormatV2.headerSize() + 5*FormatV2.entrySize())
+	assertEqual(t, r.Size(), dataStart+int64(len(unique)))
+	for _, f := range r.Files {
+		if !f.IsDir() {
+			assertEqual(t, int64(f.Offset), dataStart)
+		}
+	}
+}
+
+func TestExecuteWorkersWriteIdenticalArchives(t *testing.T) {
+	base := t.TempDir()
+	writeSyntheticTree(t, base, 4, 8, 3000, 3)
+	writeTestFiles(t, base, map[string]string{
+		"_WORK/small/a.txt": "small",
+		"_WORK/small/b.txt": "small",
+		"_WORK/small/c.txt": "",
+	})
+	// files above the limit are streamed by the writer instead of prefetched
+	defer func(limit int64) { prefetchLimit = limit }(prefetchLimit)
+	prefetchLimit = 2000
+
+	var want []byte
+	for _, workers := range []int{1, 3, 16} {
+		vm := &VM{
+			BaseDir:   base,
+			VDFName:   filepath.Join(t.TempDir(), "test.vdf"),
+			Files:     []string{"* -r"},
+			Timestamp: time.Date(2021, 11, 28, 12, 31, 40, 0, time.UTC),
+			Workers:   workers,
+		}
+		if err := vm.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(vm.VDFName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want == nil {
+			want = got
+		} else if !bytes.Equal(got, want) {
+			t.Errorf("archive written by %d workers differs from the one of a single worker", workers)
+		}
+	}
+}
+
+func TestByteBudgetWaitsForRelease(t *testing.T) {
+	b := newByteBudget(100)
+	ctx := context.Background()
+	if !b.acquire(ctx, 60) || !b.acquire(ctx, 40) {
+		t.Fatal("expected the budget to hold 100 bytes")
+	}
+
+	acquired := make(chan bool)
+	go func() { acquired <- b.acquire(ctx, 50) }()
+	select {
+	case <-acquired:
+		t.Fatal("expected acquire to wait while the budget is used up")
+	case <-time.After(20 * time.Millisecond):
+	}
+	b.release(60)
+	if !<-acquired {
+		t.Fatal("expected acquire to succeed after a release")
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if b.acquire(canceled, 50) {
+		t.Error("expected acquire to fail for a canceled context")
+	}
+}
+
+// writeSyntheticTree writes dirs*filesPerDir files of size bytes below base,
+// every dupEvery-th file repeats the content of the previous one.
+func writeSyntheticTree(b testing.TB, base string, dirs, filesPerDir, size, dupEvery int) int64 {
+	b.Helper()
+	rng := rand.New(rand.NewPCG(1, 2))
+	content := make([]byte, size)
+	var total int64
+	for d := 0; d < dirs; d++ {
+		dir := filepath.Join(base, "_WORK", fmt.Sprintf("DIR%03d", d))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			b.Fatal(err)
+		}
+		for i := 0; i < filesPerDir; i++ {
+			if dupEvery == 0 || (d*filesPerDir+i)%dupEvery != 0 {
+				for j := range content {
+					content[j] = byte(rng.Uint32())
+				}
+			}
+			if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("FILE%03d.BIN", i)), content, 0644); err != nil {
+				b.Fatal(err)
+			}
+			total += int64(size)
+		}
+	}
+	return total
+}
+
+func BenchmarkExecute(b *testing.B) {
+	for _, bm := range []struct {
+		name     string
+		dupEvery int
+	}{
+		{"unique", 0},
+		{"duplicates", 4},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			base := b.TempDir()
+			total := writeSyntheticTree(b, base, 32, 32, 64<<10, bm.dupEvery)
+			vm := &VM{
+				BaseDir: base,
+				VDFName: filepath.Join(b.TempDir(), "bench.vdf"),
+				Files:   []string{"* -r"},
+			}
+			b.SetBytes(total)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := vm.Execute(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}